@@ -3,8 +3,11 @@ module prefix
 go 1.21
 
 require (
+	filippo.io/age v1.1.1
 	github.com/fsnotify/fsnotify v1.9.0
+	github.com/robfig/cron/v3 v3.0.1
+	golang.org/x/sys v0.13.0
 	gopkg.in/yaml.v3 v3.0.1
 )
 
-require golang.org/x/sys v0.13.0 // indirect
+require golang.org/x/crypto v0.4.0 // indirect
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"flag"
+	"os"
+
+	"prefix/organize"
+)
+
+var (
+	reportUnused    = flag.Bool("report-unused", false, "run one organize pass, list destinations that matched no files, and exit")
+	diagnose        = flag.Bool("diagnose", false, "at startup, warn if many files match more than one destination")
+	profileName     = flag.String("profile", "", "name of the config profile to use (default $PREFIX_PROFILE)")
+	watchOnly       = flag.Bool("watch-only", false, "skip organizing existing files at startup and only react to new file events")
+	noCreateConfig  = flag.Bool("no-create-config", false, "fail if the config file is missing instead of creating a template")
+	strictUnused    = flag.Bool("strict-unused", false, "with --report-unused, exit non-zero if any destination matched no files")
+	seedFlag        = flag.Int64("seed", 0, "seed for randomized behavior, for reproducible runs (default: config seed, else time-based)")
+	assumeYes       = flag.Bool("yes", false, "don't ask for confirmation before a large startup pass")
+	once            = flag.Bool("once", false, "run one organize pass, print a summary, and exit instead of watching")
+	outputFormat    = flag.String("output", "text", "summary format for --once: text or json")
+	logStderr       = flag.Bool("log-stderr", false, "log to stderr instead of ~/.config/prefix/app.log")
+	cliRules        organize.RuleFlags
+	summaryInterval = flag.Duration("summary-interval", 0, "in watch mode, log cumulative stats this often, e.g. 30m (default: summary_interval_minutes)")
+	dryRun          = flag.Bool("dry-run", false, "log the moves organize passes would make without touching any files (default: dry_run)")
+	ignoreLock      = flag.Bool("ignore-lock", false, "start even if another instance holds ~/.config/prefix/prefix.lock")
+	forceFlag       = flag.Bool("force", false, "during the startup pass, overwrite existing destinations of moves, backing them up to .bak first")
+)
+
+func init() {
+	flag.Var(&cliRules, "rule", "extra destination for this run, tried before the configured ones, e.g. 'prefix=IMG_,dest=/tmp/out' (repeatable)")
+}
+
+func main() {
+	flag.Parse()
+	// "prefix once" is --once, taking the same flags after it.
+	if flag.Arg(0) == "once" {
+		flag.CommandLine.Parse(flag.Args()[1:])
+		*once = true
+	}
+
+	os.Exit(organize.Run(organize.Options{
+		Args:            flag.Args(),
+		Profile:         *profileName,
+		Rules:           cliRules,
+		DryRun:          *dryRun,
+		Seed:            *seedFlag,
+		SummaryInterval: *summaryInterval,
+		IgnoreLock:      *ignoreLock,
+		AssumeYes:       *assumeYes,
+		LogStderr:       *logStderr,
+		NoCreateConfig:  *noCreateConfig,
+		Diagnose:        *diagnose,
+		WatchOnly:       *watchOnly,
+		Once:            *once,
+		OutputFormat:    *outputFormat,
+		ReportUnused:    *reportUnused,
+		StrictUnused:    *strictUnused,
+		Force:           *forceFlag,
+	}))
+}
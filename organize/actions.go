@@ -0,0 +1,235 @@
+package organize
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	actionMove     = "move"
+	actionCopy     = "copy"
+	actionSymlink  = "symlink"
+	actionHardlink = "hardlink"
+)
+
+// errAlreadyFiled reports that a copy or link action finds its destination
+// already in place from an earlier pass.
+var errAlreadyFiled = errors.New("already filed")
+
+func validAction(action string) bool {
+	switch action {
+	case "", actionMove, actionCopy, actionSymlink, actionHardlink:
+		return true
+	}
+	return false
+}
+
+func actionName(action string) string {
+	if action == "" {
+		return actionMove
+	}
+	return action
+}
+
+// mover is fileTo for organize passes, replaced in tests to make filing
+// one file fail or panic.
+var mover = fileTo
+
+// fileTo places sourcePath at destPath using the destination's action. Only
+// move removes the source; copy and the link actions leave it in the dump.
+func fileTo(action, sourcePath, destPath string) error {
+	var err error
+	switch action {
+	case actionCopy:
+		err = copyTo(sourcePath, destPath)
+	case actionSymlink:
+		err = symlinkTo(sourcePath, destPath)
+	case actionHardlink:
+		err = hardlinkTo(sourcePath, destPath)
+	default:
+		return moveFile(sourcePath, destPath)
+	}
+	if err != nil && !errors.Is(err, errAlreadyFiled) && sourceMissing(sourcePath) {
+		return fmt.Errorf("%w: %s", errSourceGone, sourcePath)
+	}
+	return err
+}
+
+// errCaseCollision reports that the destination directory holds a file whose
+// name differs from the one being filed only in case. Such names are the
+// same file on case-insensitive filesystems (the macOS and Windows default),
+// so they are refused everywhere for consistent behavior.
+var errCaseCollision = errors.New("destination has a file differing only in case")
+
+// prepareDestination creates destPath's directory and fails with
+// errDestinationExists if something is already at destPath, or
+// errCaseCollision if a name differing only in case is.
+func prepareDestination(destPath string) error {
+	destDir := filepath.Dir(destPath)
+	if err := os.MkdirAll(destDir, 0o755); err != nil {
+		log.Printf("failed to create destination directory: %v", err)
+		return fmt.Errorf("failed to create destination directory: %w", err)
+	}
+
+	if _, err := os.Lstat(destPath); err == nil {
+		log.Printf("destination file already exists: %s", destPath)
+		return fmt.Errorf("%w: %s", errDestinationExists, destPath)
+	}
+	if other, ok := caseCollision(destPath); ok {
+		log.Printf("destination name differs only in case from existing %s", other)
+		return fmt.Errorf("%w: %s", errCaseCollision, other)
+	}
+	return nil
+}
+
+// caseCollision looks for a file next to path whose name equals path's
+// name ignoring case, and returns its path. It is only called once path
+// itself is known to be absent.
+func caseCollision(path string) (string, bool) {
+	dir, name := filepath.Split(path)
+	dir = filepath.Clean(dir)
+	other, ok := dirNames.lookup(dir, strings.ToLower(name))
+	if !ok || other == name {
+		return "", false
+	}
+	// The listing may be out of date; only a file still there collides.
+	if _, err := os.Lstat(filepath.Join(dir, other)); err != nil {
+		return "", false
+	}
+	return filepath.Join(dir, other), true
+}
+
+// dirNames caches destination directory listings for caseCollision, so
+// filing many files to one directory doesn't list it for every file.
+var dirNames = &nameCache{dirs: make(map[string]*dirListing)}
+
+// nameCache maps directories to their entries' names by lower case.
+type nameCache struct {
+	mu   sync.Mutex
+	dirs map[string]*dirListing
+}
+
+type dirListing struct {
+	modTime time.Time
+	names   map[string]string
+}
+
+// lookup returns the name in dir that lowered is the lower case of. The
+// listing is read again whenever the directory has changed since, except
+// for files noted as filed there.
+func (c *nameCache) lookup(dir, lowered string) (string, bool) {
+	info, err := os.Stat(dir)
+	if err != nil {
+		return "", false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	listing, ok := c.dirs[dir]
+	if !ok || !listing.modTime.Equal(info.ModTime()) {
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			return "", false
+		}
+		listing = &dirListing{modTime: info.ModTime(), names: make(map[string]string, len(entries))}
+		for _, entry := range entries {
+			listing.names[strings.ToLower(entry.Name())] = entry.Name()
+		}
+		c.dirs[dir] = listing
+	}
+	name, ok := listing.names[lowered]
+	return name, ok
+}
+
+// filed adds a file just filed to its directory's cached listing, which
+// stays current as long as nothing else changed the directory meanwhile.
+func (c *nameCache) filed(path string) {
+	dir, name := filepath.Split(path)
+	dir = filepath.Clean(dir)
+	info, err := os.Stat(dir)
+	if err != nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if listing, ok := c.dirs[dir]; ok {
+		listing.names[strings.ToLower(name)] = name
+		listing.modTime = info.ModTime()
+	}
+}
+
+func copyTo(sourcePath, destPath string) error {
+	if err := prepareDestination(destPath); err != nil {
+		if errors.Is(err, errDestinationExists) {
+			if same, _ := sameContent(sourcePath, destPath); same {
+				return errAlreadyFiled
+			}
+		}
+		return err
+	}
+
+	if err := copyFile(sourcePath, destPath); err != nil {
+		log.Printf("failed to copy file: %v", err)
+		return fmt.Errorf("failed to copy file: %w", err)
+	}
+	return nil
+}
+
+func symlinkTo(sourcePath, destPath string) error {
+	target, err := filepath.Abs(sourcePath)
+	if err != nil {
+		return fmt.Errorf("failed to resolve source path: %w", err)
+	}
+
+	if err := prepareDestination(destPath); err != nil {
+		if errors.Is(err, errDestinationExists) {
+			if existing, _ := os.Readlink(destPath); existing == target {
+				return errAlreadyFiled
+			}
+		}
+		return err
+	}
+
+	if err := os.Symlink(target, destPath); err != nil {
+		log.Printf("failed to create symlink: %v", err)
+		return fmt.Errorf("failed to create symlink: %w", err)
+	}
+	return nil
+}
+
+func hardlinkTo(sourcePath, destPath string) error {
+	if err := prepareDestination(destPath); err != nil {
+		if errors.Is(err, errDestinationExists) && sameFile(sourcePath, destPath) {
+			return errAlreadyFiled
+		}
+		return err
+	}
+
+	if err := os.Link(sourcePath, destPath); err == nil {
+		return nil
+	}
+
+	// Hard links can't cross devices; fall back to a copy.
+	if err := copyFile(sourcePath, destPath); err != nil {
+		log.Printf("failed to copy file: %v", err)
+		return fmt.Errorf("failed to copy file: %w", err)
+	}
+	return nil
+}
+
+func sameFile(pathA, pathB string) bool {
+	infoA, err := os.Stat(pathA)
+	if err != nil {
+		return false
+	}
+	infoB, err := os.Stat(pathB)
+	if err != nil {
+		return false
+	}
+	return os.SameFile(infoA, infoB)
+}
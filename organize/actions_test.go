@@ -0,0 +1,81 @@
+package organize
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestActions(t *testing.T) {
+	tests := []struct {
+		action     string
+		sourceKept bool
+		check      func(t *testing.T, source, dest string)
+	}{
+		{action: actionMove},
+		{action: actionCopy, sourceKept: true, check: func(t *testing.T, source, dest string) {
+			if sameFile(source, dest) {
+				t.Error("copy shares the source's inode")
+			}
+		}},
+		{action: actionSymlink, sourceKept: true, check: func(t *testing.T, source, dest string) {
+			target, err := os.Readlink(dest)
+			if err != nil {
+				t.Fatalf("destination is not a symlink: %v", err)
+			}
+			if target != source {
+				t.Errorf("symlink points at %q, want %q", target, source)
+			}
+		}},
+		{action: actionHardlink, sourceKept: true, check: func(t *testing.T, source, dest string) {
+			if !sameFile(source, dest) {
+				t.Error("hard link is a different file from the source")
+			}
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(actionName(tt.action), func(t *testing.T) {
+			dir := t.TempDir()
+			source := filepath.Join(dir, "dump", "a.txt")
+			dest := filepath.Join(dir, "out", "a.txt")
+			writeFile(t, source, "content", time.Time{})
+
+			if err := fileTo(tt.action, source, dest); err != nil {
+				t.Fatal(err)
+			}
+			if got := readFile(t, dest); got != "content" {
+				t.Errorf("destination content = %q, want %q", got, "content")
+			}
+			if _, err := os.Lstat(source); (err == nil) != tt.sourceKept {
+				t.Errorf("source kept = %v, want %v", err == nil, tt.sourceKept)
+			}
+			if tt.check != nil {
+				tt.check(t, source, dest)
+			}
+			// Filing again finds the file already filed rather than a
+			// conflict, except for a move, whose source is gone.
+			if tt.sourceKept {
+				if err := fileTo(tt.action, source, dest); !errors.Is(err, errAlreadyFiled) {
+					t.Errorf("second %s = %v, want errAlreadyFiled", actionName(tt.action), err)
+				}
+			}
+		})
+	}
+}
+
+func TestValidateAction(t *testing.T) {
+	for _, action := range []string{"", actionMove, actionCopy, actionSymlink, actionHardlink, "link"} {
+		config := &Config{DumpDirectory: t.TempDir(), Destinations: []Destination{{Prefix: "a_", Path: t.TempDir(), Action: action}}}
+		problems := config.Validate()
+		invalid := false
+		for _, problem := range problems {
+			invalid = invalid || strings.Contains(problem.Error(), "action must be one of")
+		}
+		if want := action == "link"; invalid != want {
+			t.Errorf("action %q reported invalid = %v, want %v (problems %v)", action, invalid, want, problems)
+		}
+	}
+}
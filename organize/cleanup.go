@@ -0,0 +1,118 @@
+package organize
+
+import (
+	"flag"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// cmdCleanup removes partial copies (tempSuffix files) older than a
+// threshold from every destination directory.
+func cmdCleanup(config *Config, args []string) int {
+	flags := flag.NewFlagSet("cleanup", flag.ContinueOnError)
+	olderThan := flags.Duration("older-than", time.Hour, "only remove temp files last modified longer ago than this")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+
+	release, ok := lockForCommand("cleanup")
+	if !ok {
+		return 1
+	}
+	defer release()
+
+	cutoff := clock.Now().Add(-*olderThan)
+	removed, failed := 0, 0
+	seen := make(map[string]bool)
+
+	for _, dest := range config.Destinations {
+		root, templated := staticRoot(config.rooted(dest.Path))
+		if seen[root] {
+			continue
+		}
+		seen[root] = true
+		if err := checkCleanupRoot(root); err != nil {
+			log.Printf("Not cleaning up %s: %v", dest.Path, err)
+			fmt.Printf("skipped: %s: %v\n", dest.Path, err)
+			failed++
+			continue
+		}
+
+		walkErr := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+			if err != nil {
+				if path == root && os.IsNotExist(err) {
+					return nil
+				}
+				log.Printf("failed to read %s: %v", path, err)
+				return nil
+			}
+			if d.IsDir() {
+				// Templated destinations write into subdirectories of the
+				// static root; plain ones only into the root itself.
+				if path != root && !templated {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+			if !strings.HasSuffix(d.Name(), tempSuffix) {
+				return nil
+			}
+
+			info, err := d.Info()
+			if err != nil || info.ModTime().After(cutoff) {
+				return nil
+			}
+			if err := os.Remove(path); err != nil {
+				log.Printf("failed to remove stale temp file %s: %v", path, err)
+				fmt.Printf("failed: %s: %v\n", path, err)
+				failed++
+				return nil
+			}
+			log.Printf("Removed stale temp file: %s", path)
+			fmt.Printf("removed: %s\n", path)
+			removed++
+			return nil
+		})
+		if walkErr != nil {
+			log.Printf("failed to scan %s: %v", root, walkErr)
+		}
+	}
+
+	fmt.Printf("%d stale temp files removed\n", removed)
+	if failed > 0 {
+		return 1
+	}
+	return 0
+}
+
+// checkCleanupRoot refuses to clean a root that is relative, the
+// filesystem root or the home directory, as a path starting with a
+// placeholder gives, since the walk would cover far more than the
+// destination.
+func checkCleanupRoot(root string) error {
+	if !filepath.IsAbs(root) {
+		return fmt.Errorf("its fixed part %q is not an absolute path", root)
+	}
+	if filepath.Dir(root) == root {
+		return fmt.Errorf("its fixed part is the filesystem root")
+	}
+	if home, err := os.UserHomeDir(); err == nil && filepath.Clean(home) == root {
+		return fmt.Errorf("its fixed part is the home directory")
+	}
+	return nil
+}
+
+// staticRoot returns the part of a destination path before its first
+// placeholder, and whether it had any.
+func staticRoot(path string) (string, bool) {
+	i := strings.Index(path, "{")
+	if i < 0 {
+		return filepath.Clean(path), false
+	}
+	return filepath.Dir(path[:i] + "x"), true
+}
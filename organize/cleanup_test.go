@@ -0,0 +1,77 @@
+package organize
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestStaticRoot(t *testing.T) {
+	tests := []struct {
+		path          string
+		want          string
+		wantTemplated bool
+	}{
+		{"/home/me/Docs", "/home/me/Docs", false},
+		{"/home/me/Docs/", "/home/me/Docs", false},
+		{"/home/me/Pictures/{year}/{month}", "/home/me/Pictures", true},
+		{"/home/me/Sorted/by{ext}", "/home/me/Sorted", true},
+		{"/{year}", "/", true},
+		{"{year}/docs", ".", true},
+	}
+	for _, tt := range tests {
+		got, templated := staticRoot(tt.path)
+		if got != tt.want || templated != tt.wantTemplated {
+			t.Errorf("staticRoot(%q) = %q, %v, want %q, %v", tt.path, got, templated, tt.want, tt.wantTemplated)
+		}
+	}
+}
+
+func TestCheckCleanupRoot(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	for root, wantErr := range map[string]bool{
+		"/":                         true,
+		".":                         true,
+		"docs":                      true,
+		home:                        true,
+		filepath.Join(home, "Docs"): false,
+	} {
+		if err := checkCleanupRoot(root); (err != nil) != wantErr {
+			t.Errorf("checkCleanupRoot(%q) = %v, want error %v", root, err, wantErr)
+		}
+	}
+}
+
+func TestCleanup(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	if err := os.MkdirAll(filepath.Join(home, ".config", "prefix"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	out := filepath.Join(home, "out")
+	old := time.Now().Add(-2 * time.Hour)
+	writeFile(t, filepath.Join(out, "2024", "a.pdf"+tempSuffix), "", old)
+	writeFile(t, filepath.Join(out, "2024", "b.pdf"+tempSuffix), "", time.Now())
+	writeFile(t, filepath.Join(out, "2024", "c.pdf"), "", old)
+	writeFile(t, filepath.Join(home, "elsewhere"+tempSuffix), "", old)
+	config := &Config{Destinations: []Destination{
+		{Path: filepath.Join(out, "{year}")},
+		{Path: filepath.Join(home, "{year}")},
+	}}
+
+	if code := cmdCleanup(config, nil); code != 1 {
+		t.Errorf("cleanup exited %d, want 1 for the skipped destination", code)
+	}
+	for name, wantKept := range map[string]bool{
+		filepath.Join(out, "2024", "a.pdf"+tempSuffix): false,
+		filepath.Join(out, "2024", "b.pdf"+tempSuffix): true,
+		filepath.Join(out, "2024", "c.pdf"):            true,
+		filepath.Join(home, "elsewhere"+tempSuffix):    true,
+	} {
+		if _, err := os.Lstat(name); (err == nil) != wantKept {
+			t.Errorf("%s kept = %v, want %v", name, err == nil, wantKept)
+		}
+	}
+}
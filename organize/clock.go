@@ -0,0 +1,29 @@
+package organize
+
+import "time"
+
+// Clock is the source of time for time-based behavior (debounce and settle
+// timers, age conditions, stale files), so it can be replaced to control
+// time without real sleeps.
+type Clock interface {
+	Now() time.Time
+	AfterFunc(d time.Duration, f func()) Timer
+}
+
+// Timer is a pending call scheduled with Clock.AfterFunc.
+type Timer interface {
+	// Stop cancels the call, reporting false if it already ran or was
+	// stopped.
+	Stop() bool
+}
+
+type realClock struct{}
+
+func (realClock) Now() time.Time { return time.Now() }
+
+func (realClock) AfterFunc(d time.Duration, f func()) Timer {
+	return time.AfterFunc(d, f)
+}
+
+// clock is the Clock used throughout; only replaced to control time.
+var clock Clock = realClock{}
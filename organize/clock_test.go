@@ -0,0 +1,160 @@
+package organize
+
+import (
+	"path/filepath"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeClock is a Clock that only moves when advanced, running the timers
+// that fall due on the way.
+type fakeClock struct {
+	mu     sync.Mutex
+	now    time.Time
+	timers []*fakeTimer
+}
+
+type fakeTimer struct {
+	clock *fakeClock
+	at    time.Time
+	f     func()
+}
+
+// useFakeClock replaces clock with a fakeClock at now for the rest of the
+// test.
+func useFakeClock(t *testing.T, now time.Time) *fakeClock {
+	t.Helper()
+	fake := &fakeClock{now: now}
+	old := clock
+	clock = fake
+	t.Cleanup(func() { clock = old })
+	return fake
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *fakeClock) AfterFunc(d time.Duration, f func()) Timer {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	timer := &fakeTimer{clock: c, at: c.now.Add(d), f: f}
+	c.timers = append(c.timers, timer)
+	return timer
+}
+
+// Advance moves the clock forward by d and runs the timers due by then, in
+// the order they fall due.
+func (c *fakeClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	c.now = c.now.Add(d)
+	var due, pending []*fakeTimer
+	for _, timer := range c.timers {
+		if !timer.at.After(c.now) {
+			due = append(due, timer)
+		} else {
+			pending = append(pending, timer)
+		}
+	}
+	c.timers = pending
+	c.mu.Unlock()
+
+	sort.SliceStable(due, func(i, j int) bool { return due[i].at.Before(due[j].at) })
+	for _, timer := range due {
+		timer.f()
+	}
+}
+
+func (t *fakeTimer) Stop() bool {
+	t.clock.mu.Lock()
+	defer t.clock.mu.Unlock()
+	for i, timer := range t.clock.timers {
+		if timer == t {
+			t.clock.timers = append(t.clock.timers[:i], t.clock.timers[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+func TestFakeClockTimers(t *testing.T) {
+	fake := useFakeClock(t, time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	var fired []string
+	clock.AfterFunc(2*time.Second, func() { fired = append(fired, "b") })
+	clock.AfterFunc(time.Second, func() { fired = append(fired, "a") })
+	stopped := clock.AfterFunc(time.Second, func() { fired = append(fired, "stopped") })
+	if !stopped.Stop() {
+		t.Error("Stop of a pending timer returned false")
+	}
+
+	fake.Advance(500 * time.Millisecond)
+	if len(fired) != 0 {
+		t.Fatalf("timers fired early: %v", fired)
+	}
+	fake.Advance(2 * time.Second)
+	if len(fired) != 2 || fired[0] != "a" || fired[1] != "b" {
+		t.Errorf("fired = %v, want [a b]", fired)
+	}
+	if stopped.Stop() {
+		t.Error("Stop of a stopped timer returned true")
+	}
+}
+
+func TestFilenameDateDaysAgo(t *testing.T) {
+	useFakeClock(t, time.Date(2024, 6, 30, 12, 0, 0, 0, time.Local))
+	tests := []struct {
+		name     string
+		before   string
+		after    string
+		filename string
+		want     bool
+	}{
+		{"older than 30 days", "30d", "", "scan_2024-05-01.pdf", true},
+		{"within 30 days", "30d", "", "scan_2024-06-15.pdf", false},
+		{"after 30 days ago", "", "30d", "scan_2024-06-15.pdf", true},
+		{"not after 30 days ago", "", "30d", "scan_2024-05-01.pdf", false},
+		{"no date", "30d", "", "scan.pdf", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dest := Destination{Path: t.TempDir(), FilenameDateFormat: "%Y-%m-%d", FilenameBefore: tt.before, FilenameAfter: tt.after}
+			config := &Config{DumpDirectory: t.TempDir(), Destinations: []Destination{dest}}
+			if err := config.compile(); err != nil {
+				t.Fatal(err)
+			}
+			if got := matchesFilenameDate(tt.filename, config.Destinations[0]); got != tt.want {
+				t.Errorf("matchesFilenameDate(%q) = %v, want %v", tt.filename, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestStableFor(t *testing.T) {
+	now := time.Now().Truncate(time.Second)
+	fake := useFakeClock(t, now)
+	tracker := &sizeTracker{files: make(map[string]sizeSeen)}
+	path := filepath.Join(t.TempDir(), "video.mp4")
+	writeFile(t, path, "part", now.Add(-5*time.Second))
+
+	if tracker.stable(path, 10*time.Second) {
+		t.Error("file modified 5s ago is stable for 10s")
+	}
+	fake.Advance(6 * time.Second)
+	if !tracker.stable(path, 10*time.Second) {
+		t.Error("file modified 11s ago is not stable for 10s")
+	}
+
+	// A size change seen now restarts the window even if the mtime is old.
+	writeFile(t, path, "part and more", now.Add(-time.Hour))
+	if tracker.stable(path, 10*time.Second) {
+		t.Error("file whose size just changed is stable")
+	}
+	fake.Advance(10 * time.Second)
+	if !tracker.stable(path, 10*time.Second) {
+		t.Error("file unchanged for 10s is not stable")
+	}
+}
@@ -0,0 +1,109 @@
+package organize
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// runCommand runs the subcommand named by args[0] and returns the process
+// exit code.
+func runCommand(config *Config, args []string) int {
+	switch args[0] {
+	case "match":
+		return cmdMatch(config, args[1:])
+	case "cleanup":
+		return cmdCleanup(config, args[1:])
+	case "diff-config":
+		return cmdDiffConfig(config, args[1:])
+	case "explain":
+		return cmdExplain(config, args[1:])
+	case "simulate":
+		return cmdSimulate(config, args[1:])
+	case "plan":
+		return cmdPlan(config, args[1:])
+	case "apply":
+		return cmdApply(config, args[1:])
+	case "drain":
+		return cmdDrain(args[1:])
+	default:
+		fmt.Fprintf(os.Stderr, "unknown command: %s\n", args[0])
+		return 2
+	}
+}
+
+// cmdMatch prints the destination each filename routes to. It exits 0 if
+// every name matched and 1 otherwise, so it can be used in scripts.
+func cmdMatch(config *Config, names []string) int {
+	if len(names) == 0 {
+		fmt.Fprintln(os.Stderr, "usage: prefix match <filename>...")
+		return 2
+	}
+	return printRouting(config, names)
+}
+
+// cmdSimulate is match for a list of filenames read from a file, or from
+// stdin if none is given or it is "-", one per line. Blank lines are
+// skipped. Nothing is read from the dump directory.
+func cmdSimulate(config *Config, args []string) int {
+	if len(args) > 1 {
+		fmt.Fprintln(os.Stderr, "usage: prefix simulate [file]")
+		return 2
+	}
+
+	input := os.Stdin
+	if len(args) == 1 && args[0] != "-" {
+		file, err := os.Open(args[0])
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "failed to open file list: %v\n", err)
+			return 2
+		}
+		defer file.Close()
+		input = file
+	}
+
+	var names []string
+	scanner := bufio.NewScanner(input)
+	for scanner.Scan() {
+		if name := strings.TrimSpace(scanner.Text()); name != "" {
+			names = append(names, name)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		fmt.Fprintf(os.Stderr, "failed to read file list: %v\n", err)
+		return 2
+	}
+	return printRouting(config, names)
+}
+
+// printRouting prints the destination each name routes to and returns 0
+// if every name matched, 1 otherwise.
+func printRouting(config *Config, names []string) int {
+	code := 0
+	for _, name := range names {
+		if config.ignored(name) {
+			fmt.Printf("%s: ignored (ignore_extensions)\n", name)
+			code = 1
+			continue
+		}
+		dest, ok := config.Classify(name)
+		if !ok {
+			fmt.Printf("%s: no match\n", name)
+			code = 1
+			continue
+		}
+		fmt.Printf("%s -> %s\n", name, describeDestination(config, dest, name))
+	}
+	return code
+}
+
+// describeDestination renders the directory name would be filed into,
+// falling back to the raw path template when it can't be expanded.
+func describeDestination(config *Config, dest Destination, name string) string {
+	dir, err := expandPath(dest, config.TimeSource, "", name)
+	if err != nil {
+		return fmt.Sprintf("%s (%v)", config.rooted(dest.Path), err)
+	}
+	return config.rooted(dir)
+}
@@ -0,0 +1,60 @@
+package organize
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// defaultConfirmThreshold is how many files the startup pass may move
+// before asking for confirmation.
+const defaultConfirmThreshold = 100
+
+// isTerminal reports whether f is an interactive terminal.
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+// countMatching returns how many files in the dump directory the current
+// rules would file.
+func countMatching(config *Config) (int, error) {
+	candidates, err := collectCandidates(config.dumpDirs(), config.Recursive, config.includeDirectories)
+	if err != nil {
+		return 0, err
+	}
+	n := 0
+	for _, file := range candidates {
+		if config.ignored(file.name) || config.isSidecar(file) {
+			continue
+		}
+		if len(config.routes(file.path, file.name)) > 0 {
+			n++
+		}
+	}
+	return n, nil
+}
+
+// confirmStartupPass asks on the terminal before a startup pass that would
+// move more than the confirmation threshold. It returns true without asking
+// when --yes is given or when not running interactively, e.g. as a service.
+func confirmStartupPass(config *Config) bool {
+	threshold := config.ConfirmThreshold
+	if threshold == 0 {
+		threshold = defaultConfirmThreshold
+	}
+	if options.AssumeYes || threshold < 0 || !isTerminal(os.Stdin) || !isTerminal(os.Stderr) {
+		return true
+	}
+
+	n, err := countMatching(config)
+	if err != nil || n <= threshold {
+		return true
+	}
+
+	fmt.Fprintf(os.Stderr, "About to move %d files out of %s, continue? [y/N] ", n, strings.Join(config.dumpDirs(), ", "))
+	answer, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+	answer = strings.ToLower(strings.TrimSpace(answer))
+	return answer == "y" || answer == "yes"
+}
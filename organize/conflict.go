@@ -0,0 +1,140 @@
+package organize
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	onConflictSkip      = "skip"
+	onConflictOverwrite = "overwrite"
+	onConflictRename    = "rename"
+	onConflictNewer     = "newer"
+)
+
+// errConflictSkipped reports that on_conflict left a file in place because
+// its destination already exists.
+var errConflictSkipped = errors.New("destination already exists")
+
+func validOnConflict(policy string) bool {
+	switch policy {
+	case "", onConflictSkip, onConflictOverwrite, onConflictRename, onConflictNewer:
+		return true
+	}
+	return false
+}
+
+// resolveConflict applies the on_conflict policy when something is already
+// at destPath. It returns the path to file to, which for rename is the first
+// free "name (n).ext" within the length limits of the longPath policy (see
+// fitNumberedPath), or an error wrapping errConflictSkipped when the file
+// should stay where it is. overwrite, and newer with a newer source, move
+// the existing file aside; the returned replacement removes it once the new
+// file is filed, or puts it back if filing fails. A file whose name differs
+// from destPath's only in case is a conflict like one at destPath itself. A
+// copy or link an earlier pass already made is not a conflict and is left
+// for the action to recognize.
+func resolveConflict(policy, longPath, action, sourcePath, destPath string) (string, *replacement, error) {
+	if policy == "" {
+		return destPath, nil, nil
+	}
+	existingPath := destPath
+	existing, err := os.Lstat(destPath)
+	if err != nil {
+		other, ok := caseCollision(destPath)
+		if !ok {
+			return destPath, nil, nil
+		}
+		if existing, err = os.Lstat(other); err != nil {
+			return destPath, nil, nil
+		}
+		existingPath = other
+	}
+	if existingPath == destPath && actionName(action) != actionMove {
+		if same, _ := sameContent(sourcePath, destPath); same || alreadyEncrypted(sourcePath, destPath) {
+			return destPath, nil, nil
+		}
+	}
+
+	switch policy {
+	case onConflictSkip:
+		return "", nil, fmt.Errorf("%w: %s", errConflictSkipped, existingPath)
+	case onConflictRename:
+		for n := 1; ; n++ {
+			candidate, err := fitNumberedPath(destPath, n, longPath)
+			if err != nil {
+				return "", nil, err
+			}
+			if _, err := os.Lstat(candidate); os.IsNotExist(err) {
+				if _, collides := caseCollision(candidate); collides {
+					continue
+				}
+				log.Printf("Destination exists, filing as %s", filepath.Base(candidate))
+				return candidate, nil, nil
+			}
+		}
+	case onConflictNewer:
+		source, err := os.Stat(sourcePath)
+		if err != nil {
+			return destPath, nil, nil
+		}
+		if !source.ModTime().After(existing.ModTime()) {
+			return "", nil, fmt.Errorf("%w and is not older than the source: %s", errConflictSkipped, existingPath)
+		}
+	}
+
+	if existing.IsDir() {
+		return "", nil, fmt.Errorf("%w: %s is a directory", errDestinationExists, existingPath)
+	}
+	backup := existingPath + replacedSuffix
+	if err := os.Rename(existingPath, backup); err != nil {
+		log.Printf("failed to move existing destination aside: %v", err)
+		return "", nil, fmt.Errorf("failed to move existing destination aside: %w", err)
+	}
+	log.Printf("Replacing existing destination (on_conflict %s): %s", policy, existingPath)
+	return destPath, &replacement{path: existingPath, backup: backup}, nil
+}
+
+// replacedSuffix marks an existing destination moved aside by overwrite or
+// newer until the file replacing it is in place.
+const replacedSuffix = ".prefix-replaced"
+
+// replacement is an existing destination moved aside to be replaced.
+type replacement struct {
+	path   string
+	backup string
+}
+
+// done removes the old file once the new one is filed, or restores it when
+// filing failed. It does nothing on a nil replacement.
+func (r *replacement) done(filed bool) {
+	if r == nil {
+		return
+	}
+	if filed {
+		if err := os.Remove(r.backup); err != nil {
+			log.Printf("failed to remove replaced destination: %v", err)
+		}
+		return
+	}
+	if err := os.Rename(r.backup, r.path); err != nil {
+		log.Printf("failed to restore replaced destination %s from %s: %v", r.path, r.backup, err)
+		return
+	}
+	log.Printf("Filing failed, restored existing destination: %s", r.path)
+}
+
+// numberedPath inserts " (n)" before path's extension, so "report.pdf"
+// becomes "report (1).pdf". A leading dot is not treated as an extension.
+func numberedPath(path string, n int) string {
+	dir, name := filepath.Split(path)
+	ext := filepath.Ext(name)
+	if ext == name {
+		ext = ""
+	}
+	return filepath.Join(dir, fmt.Sprintf("%s (%d)%s", strings.TrimSuffix(name, ext), n, ext))
+}
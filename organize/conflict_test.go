@@ -0,0 +1,239 @@
+package organize
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func writeFile(t *testing.T, path, content string, mtime time.Time) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if !mtime.IsZero() {
+		if err := os.Chtimes(path, mtime, mtime); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func readFile(t *testing.T, path string) string {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+func TestResolveConflict(t *testing.T) {
+	older := time.Now().Add(-time.Hour)
+	newer := time.Now()
+
+	tests := []struct {
+		name        string
+		policy      string
+		action      string
+		sourceTime  time.Time
+		destTime    time.Time
+		destName    string // of the existing file, "report.pdf" if unset
+		sameContent bool
+		wantPath    string
+		wantSkip    bool
+		wantReplace bool
+	}{
+		{name: "unset", policy: "", wantPath: "report.pdf"},
+		{name: "skip", policy: onConflictSkip, wantSkip: true},
+		{name: "rename", policy: onConflictRename, wantPath: "report (1).pdf"},
+		{name: "overwrite", policy: onConflictOverwrite, wantPath: "report.pdf", wantReplace: true},
+		{name: "newer source", policy: onConflictNewer, sourceTime: newer, destTime: older, wantPath: "report.pdf", wantReplace: true},
+		{name: "older source", policy: onConflictNewer, sourceTime: older, destTime: newer, wantSkip: true},
+		{name: "copy already made", policy: onConflictRename, action: actionCopy, sameContent: true, wantPath: "report.pdf"},
+		{name: "case only, unset", policy: "", destName: "Report.PDF", wantPath: "report.pdf"},
+		{name: "case only, skip", policy: onConflictSkip, destName: "Report.PDF", wantSkip: true},
+		{name: "case only, rename", policy: onConflictRename, destName: "Report.PDF", wantPath: "report (1).pdf"},
+		{name: "case only, overwrite", policy: onConflictOverwrite, destName: "Report.PDF", wantPath: "report.pdf", wantReplace: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			source := filepath.Join(dir, "dump", "report.pdf")
+			dest := filepath.Join(dir, "out", "report.pdf")
+			existing := dest
+			if tt.destName != "" {
+				existing = filepath.Join(dir, "out", tt.destName)
+			}
+			content := "old"
+			if tt.sameContent {
+				content = "new"
+			}
+			writeFile(t, source, "new", tt.sourceTime)
+			writeFile(t, existing, content, tt.destTime)
+
+			got, replaced, err := resolveConflict(tt.policy, "", tt.action, source, dest)
+			if tt.wantSkip {
+				if !errors.Is(err, errConflictSkipped) {
+					t.Fatalf("err = %v, want errConflictSkipped", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if want := filepath.Join(dir, "out", tt.wantPath); got != want {
+				t.Errorf("path = %s, want %s", got, want)
+			}
+			if (replaced != nil) != tt.wantReplace {
+				t.Errorf("replacement = %v, want %v", replaced, tt.wantReplace)
+			}
+			if replaced != nil && replaced.path != existing {
+				t.Errorf("replacement restores to %s, want %s", replaced.path, existing)
+			}
+		})
+	}
+}
+
+func TestCaseCollisionSeesFiledNames(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, filepath.Join(dir, "a.txt"), "", time.Time{})
+	if _, ok := caseCollision(filepath.Join(dir, "B.txt")); ok {
+		t.Fatal("collision reported in a directory without one")
+	}
+
+	writeFile(t, filepath.Join(dir, "b.txt"), "", time.Time{})
+	dirNames.filed(filepath.Join(dir, "b.txt"))
+	if other, ok := caseCollision(filepath.Join(dir, "B.txt")); !ok || other != filepath.Join(dir, "b.txt") {
+		t.Errorf("caseCollision = %q, %v, want b.txt", other, ok)
+	}
+
+	if err := os.Remove(filepath.Join(dir, "b.txt")); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := caseCollision(filepath.Join(dir, "B.txt")); ok {
+		t.Error("collision reported with a file that was removed")
+	}
+}
+
+func TestReplacementRestoresOnFailure(t *testing.T) {
+	dir := t.TempDir()
+	source := filepath.Join(dir, "report.pdf")
+	dest := filepath.Join(dir, "out", "report.pdf")
+	writeFile(t, source, "new", time.Time{})
+	writeFile(t, dest, "old", time.Time{})
+
+	_, replaced, err := resolveConflict(onConflictOverwrite, "", actionMove, source, dest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Lstat(dest); !os.IsNotExist(err) {
+		t.Fatalf("destination still in place while being replaced: %v", err)
+	}
+	replaced.done(false)
+	if got := readFile(t, dest); got != "old" {
+		t.Errorf("restored destination = %q, want %q", got, "old")
+	}
+	if _, err := os.Lstat(dest + replacedSuffix); !os.IsNotExist(err) {
+		t.Errorf("backup left behind after restore")
+	}
+}
+
+func TestReplacementRemovesBackupOnSuccess(t *testing.T) {
+	dir := t.TempDir()
+	source := filepath.Join(dir, "report.pdf")
+	dest := filepath.Join(dir, "out", "report.pdf")
+	writeFile(t, source, "new", time.Time{})
+	writeFile(t, dest, "old", time.Time{})
+
+	_, replaced, err := resolveConflict(onConflictOverwrite, "", actionMove, source, dest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := fileTo(actionMove, source, dest); err != nil {
+		t.Fatal(err)
+	}
+	replaced.done(true)
+	if got := readFile(t, dest); got != "new" {
+		t.Errorf("destination = %q, want %q", got, "new")
+	}
+	if _, err := os.Lstat(dest + replacedSuffix); !os.IsNotExist(err) {
+		t.Errorf("backup left behind after filing")
+	}
+}
+
+func TestSkipIdenticalBeforeConflictPolicy(t *testing.T) {
+	older := time.Now().Add(-time.Hour)
+	// What becomes of a source with different content: left in the dump
+	// directory, filed over the old one, or filed under a new name.
+	differing := map[string]string{
+		"":                  "left",
+		onConflictSkip:      "left",
+		onConflictOverwrite: "replaced",
+		onConflictRename:    "renamed",
+		onConflictNewer:     "replaced",
+	}
+	for policy, want := range differing {
+		for _, identical := range []bool{true, false} {
+			t.Run(fmt.Sprintf("%q/identical=%v", policy, identical), func(t *testing.T) {
+				dir := t.TempDir()
+				source := filepath.Join(dir, "dump", "a.pdf")
+				out := filepath.Join(dir, "out")
+				dest := filepath.Join(out, "a.pdf")
+				renamed := filepath.Join(out, "a (1).pdf")
+				writeFile(t, source, "new", time.Time{})
+				if identical {
+					writeFile(t, dest, "new", older)
+				} else {
+					writeFile(t, dest, "old", older)
+				}
+				before, err := os.Stat(dest)
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				p := newTestPass(t, &Config{
+					DumpDirectory: filepath.Dir(source),
+					SkipIdentical: true,
+					Destinations:  []Destination{{Prefix: "a", Path: out, OnConflict: policy}},
+				})
+				p.processFile(candidate{path: source, name: "a.pdf"})
+
+				_, sourceErr := os.Lstat(source)
+				_, renamedErr := os.Lstat(renamed)
+				after, err := os.Stat(dest)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if identical {
+					if !os.IsNotExist(sourceErr) || !os.IsNotExist(renamedErr) || !os.SameFile(before, after) {
+						t.Errorf("identical source: source err %v, renamed err %v, destination replaced %v; want the source removed and nothing filed",
+							sourceErr, renamedErr, !os.SameFile(before, after))
+					}
+					if p.result.Moved != 1 {
+						t.Errorf("moved = %d, want the source counted as moved", p.result.Moved)
+					}
+					return
+				}
+
+				var got string
+				switch {
+				case sourceErr == nil:
+					got = "left"
+				case renamedErr == nil:
+					got = "renamed"
+				case readFile(t, dest) == "new":
+					got = "replaced"
+				}
+				if got != want {
+					t.Errorf("different source was %s, want %s", got, want)
+				}
+			})
+		}
+	}
+}
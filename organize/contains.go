@@ -0,0 +1,48 @@
+package organize
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"strings"
+)
+
+// defaultContainsMaxBytes is how much of a file contains and
+// contains_regex look at unless contains_max_bytes says otherwise.
+const defaultContainsMaxBytes = 1 << 20
+
+func hasContainsConditions(dest Destination) bool {
+	return dest.Contains != "" || dest.containsRegex != nil
+}
+
+// matchesContains reports whether the start of the file at path, up to
+// ContainsMaxBytes, contains dest.Contains and matches dest.ContainsRegex.
+// Binary files, recognized by a NUL byte, never match.
+func matchesContains(path string, dest Destination) bool {
+	file, err := os.Open(path)
+	if err != nil {
+		return false
+	}
+	defer file.Close()
+
+	limit := dest.ContainsMaxBytes
+	if limit <= 0 {
+		limit = defaultContainsMaxBytes
+	}
+	content, err := io.ReadAll(io.LimitReader(file, limit))
+	if err != nil || bytes.IndexByte(content, 0) >= 0 {
+		return false
+	}
+
+	if dest.Contains != "" {
+		text := string(content)
+		needle := dest.Contains
+		if !isCaseSensitive(dest.ContainsCaseSensitive) {
+			text, needle = strings.ToLower(text), strings.ToLower(needle)
+		}
+		if !strings.Contains(text, needle) {
+			return false
+		}
+	}
+	return dest.containsRegex == nil || dest.containsRegex.Match(content)
+}
@@ -0,0 +1,46 @@
+package organize
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestContains(t *testing.T) {
+	dir := t.TempDir()
+	caseSensitive := false
+	config := &Config{DumpDirectory: dir, Destinations: []Destination{
+		{Suffix: ".txt", Contains: "Invoice", Path: filepath.Join(dir, "invoices")},
+		{Suffix: ".txt", ContainsRegex: `order #\d+`, Path: filepath.Join(dir, "orders")},
+		{Suffix: ".txt", Contains: "receipt", ContainsCaseSensitive: &caseSensitive, Path: filepath.Join(dir, "receipts")},
+		{Suffix: ".txt", Contains: "late", ContainsMaxBytes: 16, Path: filepath.Join(dir, "late")},
+	}}
+	if err := config.compile(); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name    string
+		content string
+		want    int
+	}{
+		{"invoice.txt", "Monthly Invoice for March", 0},
+		{"lowercase.txt", "monthly invoice for march", -1},
+		{"order.txt", "Thanks for order #1234", 1},
+		{"order-no-number.txt", "Thanks for your order", -1},
+		{"receipt.txt", "RECEIPT attached", 2},
+		{"binary.txt", "Invoice\x00\x01\x02", -1},
+		{"late.txt", strings.Repeat(" ", 16) + "late", -1},
+		{"early.txt", "late" + strings.Repeat(" ", 16), 3},
+		{"notes.txt", "nothing to see", -1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(dir, tt.name)
+			writeFile(t, path, tt.content, time.Time{})
+			if got, _, err := config.route(path, tt.name); err != nil || got != tt.want {
+				t.Errorf("route(%q) = %d, %v, want %d", tt.name, got, err, tt.want)
+			}
+		})
+	}
+}
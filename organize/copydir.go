@@ -0,0 +1,120 @@
+package organize
+
+import (
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// isDirectory reports whether path is a directory, not following symlinks.
+func isDirectory(path string) bool {
+	info, err := os.Lstat(path)
+	return err == nil && info.IsDir()
+}
+
+// treeState returns the total size of the regular files below dir and the
+// latest modification time of anything in it, which change while the
+// directory is still being filled.
+func treeState(dir string) (size int64, modTime time.Time) {
+	filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return nil
+		}
+		if d.Type().IsRegular() {
+			size += info.Size()
+		}
+		if info.ModTime().After(modTime) {
+			modTime = info.ModTime()
+		}
+		return nil
+	})
+	return size, modTime
+}
+
+// copyDir is copyFile for a directory tree: it recreates sourcePath under
+// destPath with the same permissions and modification times, copying
+// symlinks as symlinks. The tree is built under destPath+tempSuffix and
+// renamed into place only once everything has been copied, so a failure
+// never leaves a partial tree under the real name.
+func copyDir(sourcePath, destPath string) error {
+	tempPath := destPath + tempSuffix
+	if err := os.RemoveAll(tempPath); err != nil {
+		log.Printf("failed to remove old partial copy: %v", err)
+		return fmt.Errorf("failed to remove old partial copy: %w", err)
+	}
+	fail := func(err error) error {
+		if removeErr := os.RemoveAll(tempPath); removeErr != nil {
+			log.Printf("failed to remove partial copy: %v", removeErr)
+		}
+		return err
+	}
+
+	// Directory times are set once their contents are in place, deepest
+	// first, since creating entries in a directory updates its mtime.
+	type dirTime struct {
+		path string
+		info fs.FileInfo
+	}
+	var dirs []dirTime
+
+	err := filepath.WalkDir(sourcePath, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(sourcePath, path)
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(tempPath, rel)
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+
+		switch {
+		case d.IsDir():
+			if err := os.Mkdir(target, info.Mode().Perm()|0o700); err != nil {
+				return err
+			}
+			dirs = append(dirs, dirTime{target, info})
+			return nil
+		case d.Type()&os.ModeSymlink != 0:
+			link, err := os.Readlink(path)
+			if err != nil {
+				return err
+			}
+			return os.Symlink(link, target)
+		case d.Type().IsRegular():
+			if err := copyFile(path, target); err != nil {
+				return err
+			}
+			return os.Chtimes(target, info.ModTime(), info.ModTime())
+		default:
+			return fmt.Errorf("can't copy %s: unsupported file type %v", path, d.Type())
+		}
+	})
+	if err != nil {
+		return fail(fmt.Errorf("failed to copy directory: %w", err))
+	}
+
+	for i := len(dirs) - 1; i >= 0; i-- {
+		if err := os.Chmod(dirs[i].path, dirs[i].info.Mode().Perm()); err != nil {
+			return fail(fmt.Errorf("failed to set permissions: %w", err))
+		}
+		if err := os.Chtimes(dirs[i].path, dirs[i].info.ModTime(), dirs[i].info.ModTime()); err != nil {
+			return fail(fmt.Errorf("failed to set times: %w", err))
+		}
+	}
+
+	if err := os.Rename(tempPath, destPath); err != nil {
+		return fail(fmt.Errorf("failed to rename copy into place: %w", err))
+	}
+	return nil
+}
@@ -0,0 +1,133 @@
+package organize
+
+import (
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// crossDevice makes moves fail as they do between filesystems for the
+// rest of the test, so moveFile has to copy.
+func crossDevice(t *testing.T) {
+	t.Helper()
+	rename = func(oldpath, newpath string) error {
+		return &os.LinkError{Op: "rename", Old: oldpath, New: newpath, Err: syscall.EXDEV}
+	}
+	t.Cleanup(func() { rename = os.Rename })
+}
+
+// writeTree creates a nested directory under dir and returns its root.
+func writeTree(t *testing.T, dir string, mtime time.Time) string {
+	t.Helper()
+	root := filepath.Join(dir, "Project_X")
+	writeFile(t, filepath.Join(root, "notes.txt"), "notes", mtime)
+	writeFile(t, filepath.Join(root, "src", "main.go"), "package main", mtime)
+	writeFile(t, filepath.Join(root, "src", "deep", "data.bin"), "data", mtime)
+	if err := os.Chmod(filepath.Join(root, "src", "deep", "data.bin"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink("notes.txt", filepath.Join(root, "link")); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chtimes(filepath.Join(root, "src"), mtime, mtime); err != nil {
+		t.Fatal(err)
+	}
+	return root
+}
+
+func TestMoveDirectoryAcrossDevices(t *testing.T) {
+	crossDevice(t)
+	dir := t.TempDir()
+	mtime := time.Date(2024, 3, 15, 12, 0, 0, 0, time.UTC)
+	source := writeTree(t, filepath.Join(dir, "dump"), mtime)
+	dest := filepath.Join(dir, "out", "Project_X")
+
+	if err := moveFile(source, dest); err != nil {
+		t.Fatalf("moveFile: %v", err)
+	}
+
+	if _, err := os.Lstat(source); !os.IsNotExist(err) {
+		t.Errorf("source tree still exists: %v", err)
+	}
+	for name, want := range map[string]string{
+		"notes.txt":         "notes",
+		"src/main.go":       "package main",
+		"src/deep/data.bin": "data",
+	} {
+		if got := readFile(t, filepath.Join(dest, name)); got != want {
+			t.Errorf("%s = %q, want %q", name, got, want)
+		}
+	}
+	if link, err := os.Readlink(filepath.Join(dest, "link")); err != nil || link != "notes.txt" {
+		t.Errorf("link = %q, %v, want a symlink to notes.txt", link, err)
+	}
+	if info, err := os.Stat(filepath.Join(dest, "src", "deep", "data.bin")); err != nil || info.Mode().Perm() != 0o600 {
+		t.Errorf("data.bin mode = %v, %v, want 0600", info.Mode().Perm(), err)
+	}
+	for _, name := range []string{"notes.txt", "src"} {
+		if info, err := os.Stat(filepath.Join(dest, name)); err != nil || !info.ModTime().Equal(mtime) {
+			t.Errorf("%s mtime = %v, %v, want %v", name, info.ModTime(), err, mtime)
+		}
+	}
+	if _, err := os.Lstat(dest + tempSuffix); !os.IsNotExist(err) {
+		t.Errorf("partial copy left behind: %v", err)
+	}
+}
+
+func TestIncludeDirectories(t *testing.T) {
+	dir := t.TempDir()
+	dump := filepath.Join(dir, "dump")
+	writeTree(t, dump, time.Time{})
+	writeFile(t, filepath.Join(dump, "Project_notes.txt"), "file", time.Time{})
+	writeFile(t, filepath.Join(dump, "Other", "Project_inner.txt"), "inner", time.Time{})
+	projects := filepath.Join(dir, "projects")
+	files := filepath.Join(dir, "files")
+
+	config := &Config{
+		DumpDirectory: dump,
+		Destinations: []Destination{
+			{Prefix: "Project_", Suffix: ".txt", Path: files},
+			{Prefix: "Project_", Path: projects, IncludeDirectories: true},
+			{Prefix: "Other", Path: filepath.Join(dir, "other")},
+		},
+	}
+	if err := config.compile(); err != nil {
+		t.Fatal(err)
+	}
+	result, err := organizeWith(config, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got := readFile(t, filepath.Join(projects, "Project_X", "src", "deep", "data.bin")); got != "data" {
+		t.Errorf("moved tree has data.bin = %q", got)
+	}
+	if got := readFile(t, filepath.Join(files, "Project_notes.txt")); got != "file" {
+		t.Errorf("Project_notes.txt = %q, want it filed by the file rule", got)
+	}
+	// Other matches a rule without include_directories, so it stays.
+	if got := readFile(t, filepath.Join(dump, "Other", "Project_inner.txt")); got != "inner" {
+		t.Errorf("Other was moved: %q", got)
+	}
+	if result.Moved != 2 {
+		t.Errorf("moved = %d, want 2", result.Moved)
+	}
+}
+
+func TestIncludeDirectoriesValidation(t *testing.T) {
+	for name, dest := range map[string]Destination{
+		"copy":  {Prefix: "P", Path: "/out", IncludeDirectories: true, Action: actionCopy},
+		"magic": {Prefix: "P", Path: "/out", IncludeDirectories: true, Magic: "pdf"},
+	} {
+		config := &Config{DumpDirectory: "/dump", Destinations: []Destination{dest}}
+		if problems := config.Validate(); len(problems) == 0 {
+			t.Errorf("%s: include_directories config is valid", name)
+		}
+	}
+	config := &Config{DumpDirectory: "/dump", Recursive: true, Destinations: []Destination{{Prefix: "P", Path: "/out", IncludeDirectories: true}}}
+	if problems := config.Validate(); len(problems) == 0 {
+		t.Error("include_directories with recursive is valid")
+	}
+}
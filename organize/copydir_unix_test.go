@@ -0,0 +1,35 @@
+//go:build unix
+
+package organize
+
+import (
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestMoveDirectoryAcrossDevicesFailing(t *testing.T) {
+	crossDevice(t)
+	dir := t.TempDir()
+	source := writeTree(t, filepath.Join(dir, "dump"), time.Time{})
+	// A named pipe can't be copied, so the copy fails partway through.
+	if err := syscall.Mkfifo(filepath.Join(source, "src", "pipe"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	dest := filepath.Join(dir, "out", "Project_X")
+
+	if err := moveFile(source, dest); err == nil {
+		t.Fatal("moveFile succeeded")
+	}
+
+	for _, path := range []string{dest, dest + tempSuffix} {
+		if _, err := os.Lstat(path); !os.IsNotExist(err) {
+			t.Errorf("%s left behind: %v", path, err)
+		}
+	}
+	if got := readFile(t, filepath.Join(source, "src", "deep", "data.bin")); got != "data" {
+		t.Errorf("source tree damaged: data.bin = %q", got)
+	}
+}
@@ -0,0 +1,72 @@
+package organize
+
+import (
+	"fmt"
+	"log"
+	"sort"
+)
+
+// overlapWarnPercent is the share of files matching more than one
+// destination above which diagnose warns.
+const overlapWarnPercent = 10
+
+// diagnoseOverlap checks every candidate against every destination (without
+// the first-match break) and warns when many files match several rules.
+func diagnoseOverlap(config *Config) {
+	all, err := collectCandidates(config.dumpDirs(), config.Recursive, config.includeDirectories)
+	if err != nil {
+		log.Printf("Diagnose: %v", err)
+		return
+	}
+	// Files processFile never routes don't count towards the overlap.
+	var candidates []candidate
+	for _, c := range all {
+		if !config.ignored(c.name) && !config.isSidecar(c) {
+			candidates = append(candidates, c)
+		}
+	}
+	if len(candidates) == 0 {
+		log.Println("Diagnose: dump directory is empty, nothing to check")
+		return
+	}
+
+	overlapping := 0
+	pairs := make(map[[2]int]int)
+	for _, c := range candidates {
+		var matched []int
+		for i, dest := range config.Destinations {
+			if matchesPattern(c.name, dest) && matchesFileConditions(c.path, dest) {
+				matched = append(matched, i)
+			}
+		}
+		if len(matched) < 2 {
+			continue
+		}
+		overlapping++
+		for _, other := range matched[1:] {
+			pairs[[2]int{matched[0], other}]++
+		}
+	}
+
+	percent := overlapping * 100 / len(candidates)
+	log.Printf("Diagnose: %d of %d files (%d%%) match more than one destination", overlapping, len(candidates), percent)
+	if percent <= overlapWarnPercent {
+		return
+	}
+
+	log.Printf("WARNING: rules overlap heavily; only the first match is used. Consider reordering rules or making them more specific")
+	keys := make([][2]int, 0, len(pairs))
+	for pair := range pairs {
+		keys = append(keys, pair)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if pairs[keys[i]] != pairs[keys[j]] {
+			return pairs[keys[i]] > pairs[keys[j]]
+		}
+		return fmt.Sprint(keys[i]) < fmt.Sprint(keys[j])
+	})
+	for _, pair := range keys {
+		log.Printf("  destination[%d] (%s) shadows destination[%d] (%s) for %d files",
+			pair[0], config.Destinations[pair[0]].Path, pair[1], config.Destinations[pair[1]].Path, pairs[pair])
+	}
+}
@@ -0,0 +1,79 @@
+package organize
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// cmdDiffConfig classifies the files currently in the dump directory with
+// two configs and prints those whose destination differs.
+func cmdDiffConfig(config *Config, args []string) int {
+	flags := flag.NewFlagSet("diff-config", flag.ContinueOnError)
+	dump := flags.String("dump", "", "directory to classify (default: the new config's dump directories)")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	if flags.NArg() != 2 {
+		fmt.Fprintln(os.Stderr, "usage: prefix diff-config [--dump dir] <old.yaml> <new.yaml>")
+		return 2
+	}
+
+	oldConfig, err := loadConfigFile(flags.Arg(0), activeProfile())
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to load %s: %v\n", flags.Arg(0), err)
+		return 2
+	}
+	newConfig, err := loadConfigFile(flags.Arg(1), activeProfile())
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to load %s: %v\n", flags.Arg(1), err)
+		return 2
+	}
+
+	dirs := newConfig.dumpDirs()
+	if *dump != "" {
+		dirs = []string{*dump}
+	} else if len(dirs) == 0 {
+		dirs = config.dumpDirs()
+	}
+
+	candidates, err := collectCandidates(dirs, newConfig.Recursive, newConfig.includeDirectories)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		return 2
+	}
+
+	changed := 0
+	for _, c := range candidates {
+		before := describeRoute(oldConfig, c)
+		after := describeRoute(newConfig, c)
+		if before == after {
+			continue
+		}
+		// With several dump directories the full path says which one.
+		rel := c.path
+		if len(dirs) == 1 {
+			if r, err := filepath.Rel(dirs[0], c.path); err == nil {
+				rel = r
+			}
+		}
+		fmt.Printf("%s: %s -> %s\n", rel, before, after)
+		changed++
+	}
+
+	fmt.Printf("%d of %d files would be routed differently\n", changed, len(candidates))
+	return 0
+}
+
+// describeRoute renders where config would send the candidate.
+func describeRoute(config *Config, c candidate) string {
+	i, dir, err := config.route(c.path, c.name)
+	if i < 0 {
+		return "no match"
+	}
+	if err != nil {
+		return fmt.Sprintf("error (%v)", err)
+	}
+	return dir
+}
@@ -0,0 +1,18 @@
+//go:build !unix
+
+package organize
+
+import "path/filepath"
+
+// dirID identifies the directory at path by its fully resolved path.
+func dirID(path string) (string, bool) {
+	resolved, err := filepath.EvalSymlinks(path)
+	if err != nil {
+		return "", false
+	}
+	abs, err := filepath.Abs(resolved)
+	if err != nil {
+		return "", false
+	}
+	return abs, true
+}
@@ -0,0 +1,23 @@
+//go:build unix
+
+package organize
+
+import (
+	"fmt"
+	"os"
+	"syscall"
+)
+
+// dirID identifies the directory at path by device and inode, following
+// symlinks.
+func dirID(path string) (string, bool) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return "", false
+	}
+	stat, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return "", false
+	}
+	return fmt.Sprintf("%d:%d", stat.Dev, stat.Ino), true
+}
@@ -0,0 +1,54 @@
+package organize
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"syscall"
+	"time"
+)
+
+// cmdDrain asks the running organizer, found through the PID in its lock
+// file, to run a final organize pass and exit (by sending it SIGQUIT), then
+// waits for it to be gone.
+func cmdDrain(args []string) int {
+	flags := flag.NewFlagSet("drain", flag.ContinueOnError)
+	wait := flags.Duration("wait", 10*time.Minute, "how long to wait for the organizer to exit")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+
+	dir, err := configDir()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	lockPath := filepath.Join(dir, "prefix.lock")
+	pid := lockHolder(lockPath)
+	if pid == 0 || pid == os.Getpid() {
+		fmt.Fprintf(os.Stderr, "no running organizer found in %s\n", lockPath)
+		return 1
+	}
+
+	process, err := os.FindProcess(pid)
+	if err == nil {
+		err = process.Signal(syscall.SIGQUIT)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to signal organizer (PID %d): %v\n", pid, err)
+		return 1
+	}
+	fmt.Printf("Asked organizer (PID %d) to drain, waiting for it to exit...\n", pid)
+
+	deadline := clock.Now().Add(*wait)
+	for clock.Now().Before(deadline) {
+		if process.Signal(syscall.Signal(0)) != nil {
+			fmt.Println("Organizer drained and exited")
+			return 0
+		}
+		time.Sleep(200 * time.Millisecond)
+	}
+	fmt.Fprintf(os.Stderr, "organizer (PID %d) still running after %v\n", pid, *wait)
+	return 1
+}
@@ -0,0 +1,42 @@
+package organize
+
+import (
+	"log"
+	"os"
+)
+
+// dryRun logs where the file would be filed without touching it, counting
+// each destination as a move so the pass summary shows what would happen.
+// Scans, hash lists and everything else that acts on the file are left out.
+func (p *passState) dryRun(file candidate, targets []routeTarget) {
+	var size int64
+	if info, err := os.Lstat(file.path); err == nil {
+		size = info.Size()
+	}
+
+	filed := 0
+	for _, target := range targets {
+		p.matched(target.rule)
+		destPath, err := p.config.destinationPath(target, file.path, file.name)
+		if err != nil {
+			log.Printf("Dry run: would not file %s: %v", file.name, err)
+			continue
+		}
+
+		action := actionName(p.config.Destinations[target.rule].Action)
+		if _, err := os.Lstat(destPath); err == nil {
+			policy := p.config.Destinations[target.rule].OnConflict
+			if policy == "" {
+				policy = "none"
+			}
+			log.Printf("Dry run: would file (%s): %s -> %s (destination exists, on_conflict %s)", action, file.path, destPath, policy)
+		} else {
+			log.Printf("Dry run: would file (%s): %s -> %s", action, file.path, destPath)
+		}
+		filed++
+		p.moved(FileMove{Source: file.path, Destination: destPath, Size: size, Rule: target.rule})
+	}
+	if filed == 0 {
+		p.skipped()
+	}
+}
@@ -0,0 +1,133 @@
+package organize
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"filippo.io/age"
+)
+
+// encryptedSuffix is appended to the name of files filed encrypted.
+const encryptedSuffix = ".age"
+
+// encryptedHashSuffix names the file next to a ciphertext recording the
+// SHA-256 of its plaintext, in sha256sum format. The ciphertext can't be
+// compared with its source, so this is how a later pass knows a copy is
+// already filed.
+const encryptedHashSuffix = ".sha256"
+
+// fileEncrypted is fileTo for destinations with encrypt: the file is
+// encrypted to recipient at destPath, and with the move action the source
+// is removed once the ciphertext is in place. A copy whose plaintext is
+// already encrypted at destPath is not filed again.
+func fileEncrypted(action, sourcePath, destPath string, recipient age.Recipient) error {
+	if actionName(action) != actionMove && alreadyEncrypted(sourcePath, destPath) {
+		return errAlreadyFiled
+	}
+	err := encryptTo(sourcePath, destPath, recipient)
+	if err != nil {
+		if sourceMissing(sourcePath) {
+			return fmt.Errorf("%w: %s", errSourceGone, sourcePath)
+		}
+		return err
+	}
+	if actionName(action) == actionMove {
+		if err := os.Remove(sourcePath); err != nil {
+			log.Printf("failed to remove source file: %v", err)
+			return fmt.Errorf("failed to remove source file: %w", err)
+		}
+	}
+	return nil
+}
+
+// encryptTo streams sourcePath through age into destPath. Like copyFile it
+// writes to a temp file renamed into place, and only ciphertext is ever
+// written, so a failure can't leave plaintext in the destination.
+func encryptTo(sourcePath, destPath string, recipient age.Recipient) error {
+	err := prepareDestination(destPath)
+	if errors.Is(err, errDestinationExists) && forceOverwrite.Load() {
+		err = backupExisting(destPath)
+	}
+	if err != nil {
+		return err
+	}
+
+	sourceFile, err := os.Open(sourcePath)
+	if err != nil {
+		log.Printf("failed to open source file: %v", err)
+		return fmt.Errorf("failed to open source file: %w", err)
+	}
+	defer sourceFile.Close()
+
+	sourceInfo, err := sourceFile.Stat()
+	if err != nil {
+		log.Printf("failed to stat source file: %v", err)
+		return fmt.Errorf("failed to stat source file: %w", err)
+	}
+
+	tempPath := destPath + tempSuffix
+	destFile, err := os.OpenFile(tempPath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0o600)
+	if err != nil {
+		log.Printf("failed to create destination file: %v", err)
+		return fmt.Errorf("failed to create destination file: %w", err)
+	}
+	fail := func(err error) error {
+		destFile.Close()
+		if removeErr := os.Remove(tempPath); removeErr != nil {
+			log.Printf("failed to remove partial copy: %v", removeErr)
+		}
+		return err
+	}
+
+	encrypter, err := age.Encrypt(destFile, recipient)
+	if err != nil {
+		return fail(fmt.Errorf("failed to start encryption: %w", err))
+	}
+	hash := sha256.New()
+	source := io.TeeReader(withRateLimit(withProgress(sourceFile, sourcePath, sourceInfo.Size())), hash)
+	if _, err := io.Copy(encrypter, source); err != nil {
+		return fail(fmt.Errorf("failed to encrypt file content: %w", err))
+	}
+	if err := encrypter.Close(); err != nil {
+		return fail(fmt.Errorf("failed to finish encryption: %w", err))
+	}
+
+	if err := destFile.Close(); err != nil {
+		log.Printf("failed to close destination file: %v", err)
+		return fail(fmt.Errorf("failed to close destination file: %w", err))
+	}
+	if err := os.Rename(tempPath, destPath); err != nil {
+		return fail(fmt.Errorf("failed to rename copy into place: %w", err))
+	}
+
+	record := fmt.Sprintf("%s  %s\n", hex.EncodeToString(hash.Sum(nil)), filepath.Base(destPath))
+	if err := os.WriteFile(destPath+encryptedHashSuffix, []byte(record), 0o600); err != nil {
+		log.Printf("failed to record plaintext hash: %v", err)
+	}
+	return nil
+}
+
+// alreadyEncrypted reports whether destPath is a ciphertext whose recorded
+// plaintext hash matches sourcePath.
+func alreadyEncrypted(sourcePath, destPath string) bool {
+	record, err := os.ReadFile(destPath + encryptedHashSuffix)
+	if err != nil {
+		return false
+	}
+	if _, err := os.Lstat(destPath); err != nil {
+		return false
+	}
+	fields := strings.Fields(string(record))
+	if len(fields) == 0 {
+		return false
+	}
+	hash, err := fileSHA256(sourcePath)
+	return err == nil && hash == fields[0]
+}
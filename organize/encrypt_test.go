@@ -0,0 +1,97 @@
+package organize
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"filippo.io/age"
+)
+
+func TestEncryptedCopyFiledOnce(t *testing.T) {
+	identity, err := age.GenerateX25519Identity()
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	source := filepath.Join(dir, "dump", "a.txt")
+	dest := filepath.Join(dir, "out", "a.txt"+encryptedSuffix)
+	writeFile(t, source, "plaintext", time.Time{})
+
+	if err := fileEncrypted(actionCopy, source, dest, identity.Recipient()); err != nil {
+		t.Fatal(err)
+	}
+	if err := fileEncrypted(actionCopy, source, dest, identity.Recipient()); !errors.Is(err, errAlreadyFiled) {
+		t.Errorf("second filing = %v, want errAlreadyFiled", err)
+	}
+	resolved, _, err := resolveConflict(onConflictRename, "", actionCopy, source, dest)
+	if err != nil || resolved != dest {
+		t.Errorf("resolveConflict = %q, %v, want %q with no error", resolved, err, dest)
+	}
+
+	writeFile(t, source, "changed", time.Time{})
+	if err := fileEncrypted(actionCopy, source, dest, identity.Recipient()); !errors.Is(err, errDestinationExists) {
+		t.Errorf("filing changed source = %v, want errDestinationExists", err)
+	}
+
+	if err := undoFiling(actionCopy, source, dest); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Lstat(dest + encryptedHashSuffix); !os.IsNotExist(err) {
+		t.Errorf("hash record left after undo: %v", err)
+	}
+}
+
+func TestEncryptRoundTrip(t *testing.T) {
+	identity, err := age.GenerateX25519Identity()
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	source := filepath.Join(dir, "dump", "a.bin")
+	dest := filepath.Join(dir, "out", "a.bin"+encryptedSuffix)
+	// Larger than one age chunk, with every byte value.
+	var plaintext []byte
+	for i := 0; i < 200_000; i++ {
+		plaintext = append(plaintext, byte(i*7))
+	}
+	writeFile(t, source, string(plaintext), time.Time{})
+
+	if err := fileEncrypted(actionMove, source, dest, identity.Recipient()); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Lstat(source); !os.IsNotExist(err) {
+		t.Errorf("source left after an encrypted move: %v", err)
+	}
+	ciphertext, err := os.ReadFile(dest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Contains(ciphertext, plaintext[:64]) {
+		t.Error("destination contains plaintext")
+	}
+
+	r, err := age.Decrypt(bytes.NewReader(ciphertext), identity)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, plaintext) {
+		t.Errorf("decrypted %d bytes that differ from the %d-byte plaintext", len(got), len(plaintext))
+	}
+
+	other, err := age.GenerateX25519Identity()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := age.Decrypt(bytes.NewReader(ciphertext), other); err == nil {
+		t.Error("decrypted with an identity that isn't a recipient")
+	}
+}
@@ -0,0 +1,67 @@
+package organize
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// envPrefix starts the name of every environment override.
+const envPrefix = "PREFIX_"
+
+// applyEnv overrides top-level scalar options from the environment: option
+// debounce_seconds is read from PREFIX_DEBOUNCE_SECONDS, and so on. It runs
+// after the profile is applied, so the environment wins over both the file
+// and the profile. Lists and maps such as destinations can't be overridden.
+func (c *Config) applyEnv() error {
+	var errs []error
+	v := reflect.ValueOf(c).Elem()
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		name, _, _ := strings.Cut(field.Tag.Get("yaml"), ",")
+		if name == "" || name == "-" || !field.IsExported() {
+			continue
+		}
+		key := envPrefix + strings.ToUpper(name)
+		value, ok := os.LookupEnv(key)
+		if !ok {
+			continue
+		}
+		if err := setScalar(v.Field(i), value); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", key, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// setScalar parses value into f. Kinds other than strings, bools and
+// numbers are left alone.
+func setScalar(f reflect.Value, value string) error {
+	switch f.Kind() {
+	case reflect.String:
+		f.SetString(value)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return fmt.Errorf("invalid boolean %q", value)
+		}
+		f.SetBool(b)
+	case reflect.Int, reflect.Int64:
+		n, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return fmt.Errorf("invalid integer %q", value)
+		}
+		f.SetInt(n)
+	case reflect.Float64:
+		n, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return fmt.Errorf("invalid number %q", value)
+		}
+		f.SetFloat(n)
+	}
+	return nil
+}
@@ -0,0 +1,68 @@
+package organize
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestEnvOverrides(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "prefix.yaml")
+	writeFile(t, path, `dump_directory: `+filepath.Join(dir, "file")+`
+debounce_seconds: 2
+workers: 2
+dry_run: true
+profiles:
+  laptop:
+    dump_directory: `+filepath.Join(dir, "profile")+`
+destinations:
+  - prefix: a_
+    path: `+filepath.Join(dir, "out")+`
+`, time.Time{})
+
+	t.Setenv("PREFIX_DUMP_DIRECTORY", filepath.Join(dir, "env"))
+	t.Setenv("PREFIX_DEBOUNCE_SECONDS", "5.5")
+	t.Setenv("PREFIX_WORKERS", "8")
+	t.Setenv("PREFIX_RECURSIVE", "true")
+	config, err := loadConfigFile(path, "laptop")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if config.DumpDirectory != filepath.Join(dir, "env") {
+		t.Errorf("dump_directory = %q, want the environment's over the profile's", config.DumpDirectory)
+	}
+	if config.DebounceSeconds != 5.5 || config.Workers != 8 || !config.Recursive {
+		t.Errorf("debounce_seconds %v, workers %d, recursive %v, want 5.5, 8, true", config.DebounceSeconds, config.Workers, config.Recursive)
+	}
+	if !config.DryRun {
+		t.Error("dry_run from the file lost without PREFIX_DRY_RUN")
+	}
+
+	tests := []struct {
+		key, value, wantErr string
+	}{
+		{"PREFIX_WORKERS", "many", `PREFIX_WORKERS: invalid integer "many"`},
+		{"PREFIX_DRY_RUN", "maybe", `PREFIX_DRY_RUN: invalid boolean "maybe"`},
+		{"PREFIX_DEBOUNCE_SECONDS", "soon", `PREFIX_DEBOUNCE_SECONDS: invalid number "soon"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.key+"="+tt.value, func(t *testing.T) {
+			t.Setenv(tt.key, tt.value)
+			if _, err := loadConfigFile(path, ""); err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("loadConfigFile error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+
+	// Overridden values are validated like the file's.
+	t.Setenv("PREFIX_DEBOUNCE_SECONDS", "-1")
+	config, err = loadConfigFile(path, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if problems := config.Validate(); len(problems) != 1 || !strings.Contains(problems[0].Error(), "debounce_seconds must not be negative") {
+		t.Errorf("Validate = %v, want the negative debounce_seconds", problems)
+	}
+}
@@ -0,0 +1,145 @@
+package organize
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"os"
+	"sync"
+	"time"
+)
+
+// Event is one entry in the event stream served on socket_path.
+type Event struct {
+	Type        string    `json:"type"`
+	Time        time.Time `json:"time"`
+	Source      string    `json:"source,omitempty"`
+	Destination string    `json:"destination,omitempty"`
+	Error       string    `json:"error,omitempty"`
+	Moved       int       `json:"moved,omitempty"`
+	Skipped     int       `json:"skipped,omitempty"`
+}
+
+const (
+	eventPassStart   = "pass_start"
+	eventPassEnd     = "pass_end"
+	eventMoved       = "moved"
+	eventSkipped     = "skipped"
+	eventError       = "error"
+	eventQuarantined = "quarantined"
+	eventStale       = "stale"
+	eventDeleted     = "deleted"
+)
+
+// eventHub fans events out to every connected subscriber. Slow subscribers
+// drop events rather than block organizing.
+type eventHub struct {
+	mu          sync.Mutex
+	subscribers map[chan []byte]struct{}
+}
+
+var events = &eventHub{subscribers: make(map[chan []byte]struct{})}
+
+func (h *eventHub) publish(ev Event) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if len(h.subscribers) == 0 {
+		return
+	}
+
+	ev.Time = time.Now()
+	line, err := json.Marshal(ev)
+	if err != nil {
+		log.Printf("failed to encode event: %v", err)
+		return
+	}
+	line = append(line, '\n')
+
+	for ch := range h.subscribers {
+		select {
+		case ch <- line:
+		default:
+		}
+	}
+}
+
+func (h *eventHub) subscribe() chan []byte {
+	ch := make(chan []byte, 64)
+	h.mu.Lock()
+	h.subscribers[ch] = struct{}{}
+	h.mu.Unlock()
+	return ch
+}
+
+func (h *eventHub) unsubscribe(ch chan []byte) {
+	h.mu.Lock()
+	delete(h.subscribers, ch)
+	h.mu.Unlock()
+}
+
+// serveEvents listens on a Unix socket at path and streams newline-delimited
+// JSON events to each client. Close the returned listener to stop it.
+func serveEvents(path string) (net.Listener, error) {
+	// A socket left behind by an unclean exit would make Listen fail.
+	if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+
+	listener, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, err
+	}
+	// Events name every file filed, so only the owner may connect.
+	if err := os.Chmod(path, 0o600); err != nil {
+		listener.Close()
+		log.Printf("failed to restrict event socket permissions: %v", err)
+		return nil, fmt.Errorf("failed to restrict event socket permissions: %w", err)
+	}
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				if !errors.Is(err, net.ErrClosed) {
+					log.Printf("event socket accept failed: %v", err)
+				}
+				return
+			}
+			go streamEvents(conn)
+		}
+	}()
+
+	return listener, nil
+}
+
+func streamEvents(conn net.Conn) {
+	defer conn.Close()
+
+	ch := events.subscribe()
+	defer events.unsubscribe(ch)
+
+	// Detect the client hanging up even while no events are flowing.
+	closed := make(chan struct{})
+	go func() {
+		buf := make([]byte, 1)
+		for {
+			if _, err := conn.Read(buf); err != nil {
+				close(closed)
+				return
+			}
+		}
+	}()
+
+	for {
+		select {
+		case line := <-ch:
+			if _, err := conn.Write(line); err != nil {
+				return
+			}
+		case <-closed:
+			return
+		}
+	}
+}
@@ -0,0 +1,24 @@
+package organize
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestEventSocketPermissions(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "events.sock")
+	listener, err := serveEvents(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if perm := info.Mode().Perm(); perm != 0o600 {
+		t.Errorf("socket permissions = %v, want 0600", perm)
+	}
+}
@@ -0,0 +1,45 @@
+package organize_test
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+
+	"prefix/organize"
+)
+
+func ExampleConfig_Classify() {
+	config, err := organize.LoadConfig(false, "")
+	if err != nil {
+		log.Fatal(err)
+	}
+	if dest, ok := config.Classify("IMG_0042.jpg"); ok {
+		fmt.Println("IMG_0042.jpg ->", dest.Path)
+	}
+}
+
+func ExampleOrganizer() {
+	config, err := organize.LoadConfig(false, "")
+	if err != nil {
+		log.Fatal(err)
+	}
+	if problems := config.Validate(); len(problems) > 0 {
+		log.Fatal(problems[0])
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	// File what is already there, then keep filing new files until
+	// interrupted.
+	if _, err := organize.Organize(config); err != nil {
+		log.Print(err)
+	}
+	organizer := organize.NewOrganizer(config)
+	if err := organizer.Start(ctx); err != nil {
+		log.Fatal(err)
+	}
+	<-ctx.Done()
+}
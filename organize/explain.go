@@ -0,0 +1,157 @@
+package organize
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// cmdExplain walks the destinations in match order for each file and prints
+// which conditions held, stopping at the first full match. Arguments may be
+// bare names or paths; files that exist (as given or in the dump directory)
+// also have their content, owner and sidecar conditions checked.
+func cmdExplain(config *Config, args []string) int {
+	if len(args) == 0 {
+		fmt.Fprintln(os.Stderr, "usage: prefix explain <filename>...")
+		return 2
+	}
+
+	code := 0
+	for _, arg := range args {
+		if !explainFile(config, arg) {
+			code = 1
+		}
+	}
+	return code
+}
+
+func explainFile(config *Config, arg string) bool {
+	name := filepath.Base(arg)
+	path := ""
+	paths := []string{arg}
+	for _, dir := range config.dumpDirs() {
+		paths = append(paths, filepath.Join(dir, name))
+	}
+	for _, p := range paths {
+		if info, err := os.Stat(p); err == nil && !info.IsDir() {
+			path = p
+			break
+		}
+	}
+
+	if path == "" {
+		fmt.Printf("%s (name only, file not found):\n", name)
+	} else {
+		fmt.Printf("%s (%s):\n", name, path)
+	}
+	if config.ignored(name) {
+		fmt.Println("  ignored: its extension is in ignore_extensions, so no destination is tried")
+		return false
+	}
+
+	for i, dest := range config.Destinations {
+		label := fmt.Sprintf("destination[%d]", i)
+		if dest.Name != "" {
+			label += " " + dest.Name
+		}
+
+		failed := explainConditions(path, name, dest)
+		if len(failed) > 0 {
+			for _, reason := range failed {
+				fmt.Printf("  %s: no match: %s\n", label, reason)
+			}
+			continue
+		}
+
+		dir, err := expandPath(dest, config.TimeSource, path, name)
+		if errors.Is(err, errSkipRule) {
+			fmt.Printf("  %s: skipped: %v\n", label, err)
+			continue
+		}
+		if err != nil {
+			fmt.Printf("  %s: match, but the path can't be built: %v\n", label, err)
+			return true
+		}
+		fmt.Printf("  %s: match -> %s\n", label, config.rooted(dir))
+		return true
+	}
+
+	fmt.Println("  no destination matched")
+	return false
+}
+
+// explainConditions returns why dest does not match the file, one reason per
+// failed condition, or nothing if it matches. File conditions are reported
+// as unchecked when path is empty.
+func explainConditions(path, filename string, dest Destination) []string {
+	var failed []string
+
+	if len(dest.Exact) > 0 && !matchesExact(filename, dest) {
+		failed = append(failed, fmt.Sprintf("%q is not one of the exact names %q", filename, dest.Exact))
+	}
+
+	if len(dest.Extensions) > 0 && !matchesExtension(filename, dest.Extensions) {
+		failed = append(failed, fmt.Sprintf("%q does not have one of the extensions %q", filename, dest.Extensions))
+	}
+
+	if dest.pattern != nil && !dest.pattern.MatchString(filename) {
+		failed = append(failed, fmt.Sprintf("%q does not match pattern %q", filename, dest.Pattern))
+	}
+
+	if dest.regex != nil && !dest.regex.MatchString(filename) {
+		failed = append(failed, fmt.Sprintf("%q does not match regex %q", filename, dest.Regex))
+	}
+
+	if dest.filenameDate != nil && !matchesFilenameDate(filename, dest) {
+		if _, ok := filenameDate(filename, dest); !ok {
+			failed = append(failed, fmt.Sprintf("%q has no date in filename_date_format %q", filename, dest.FilenameDateFormat))
+		} else {
+			failed = append(failed, fmt.Sprintf("date in %q is outside filename_before/filename_after", filename))
+		}
+	}
+
+	name := filename
+	if dest.MatchStem {
+		name = stem(filename, dest.StemExtension)
+	}
+	if dest.Prefix != "" && !hasPrefix(name, dest.Prefix, isCaseSensitive(dest.PrefixCaseSensitive)) {
+		failed = append(failed, fmt.Sprintf("%q does not start with prefix %q", name, dest.Prefix))
+	}
+	if dest.Suffix != "" && !matchesSuffix(name, dest, isCaseSensitive(dest.SuffixCaseSensitive)) {
+		failed = append(failed, fmt.Sprintf("%q does not end with suffix %q", name, dest.Suffix))
+	}
+
+	if !hasFileConditions(dest) {
+		return failed
+	}
+	if path == "" {
+		return append(failed, "has magic, match_owner, sidecar, dump_directories, source_dir, contains or image size conditions, which need an existing file")
+	}
+	if len(dest.DumpDirectories) > 0 && !inDumpDirectory(path, dest.DumpDirectories) {
+		failed = append(failed, fmt.Sprintf("not in dump directories %q", dest.DumpDirectories))
+	}
+	if dest.SourceDir != "" && !matchesSourceDir(path, dest.SourceDir) {
+		failed = append(failed, fmt.Sprintf("not in source directory %q", dest.SourceDir))
+	}
+	if dest.MatchOwner != "" && !matchesOwner(path, dest) {
+		failed = append(failed, fmt.Sprintf("not owned by %q", dest.MatchOwner))
+	}
+	if len(dest.magic) > 0 && !hasMagic(path, dest.magic) {
+		failed = append(failed, fmt.Sprintf("content does not start with magic %q", dest.Magic))
+	}
+	if hasSidecarConditions(dest) && !matchesSidecar(path, dest) {
+		failed = append(failed, fmt.Sprintf("sidecar %s is missing or does not match", filepath.Base(path)+dest.sidecarSuffix))
+	}
+	if hasContainsConditions(dest) && !matchesContains(path, dest) {
+		failed = append(failed, "text does not contain the contains/contains_regex pattern, or the file is binary")
+	}
+	if hasDimensionConditions(dest) && !matchesDimensions(path, dest) {
+		if width, height, ok := imageSize(path); ok {
+			failed = append(failed, fmt.Sprintf("image size %dx%d is outside min/max width/height", width, height))
+		} else {
+			failed = append(failed, "not a PNG, JPEG or GIF image, needed for min/max width/height")
+		}
+	}
+	return failed
+}
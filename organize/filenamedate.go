@@ -0,0 +1,125 @@
+package organize
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// filenameDatePatterns is the text each strftime conversion matches in a
+// file name when looking for a filename_date_format date.
+var filenameDatePatterns = map[byte]string{
+	'Y': `\d{4}`,
+	'y': `\d{2}`,
+	'm': `\d{2}`,
+	'd': `\d{2}`,
+	'H': `\d{2}`,
+	'I': `\d{2}`,
+	'M': `\d{2}`,
+	'S': `\d{2}`,
+	'p': `[AaPp][Mm]`,
+	'b': `[A-Za-z]{3}`,
+	'B': `[A-Za-z]+`,
+}
+
+// compileFilenameDate turns a strftime-style format such as "%Y-%m-%d"
+// into a regexp finding such a date anywhere in a name, and the Go layout
+// parsing it.
+func compileFilenameDate(format string) (*regexp.Regexp, string, error) {
+	var pattern, layout strings.Builder
+	for i := 0; i < len(format); i++ {
+		if format[i] != '%' {
+			pattern.WriteString(regexp.QuoteMeta(format[i : i+1]))
+			layout.WriteByte(format[i])
+			continue
+		}
+		if i+1 == len(format) {
+			return nil, "", fmt.Errorf("filename_date_format %q ends with %%", format)
+		}
+		i++
+		c := format[i]
+		if c == '%' {
+			pattern.WriteString("%")
+			layout.WriteByte('%')
+			continue
+		}
+		re, ok := filenameDatePatterns[c]
+		if !ok {
+			return nil, "", fmt.Errorf("filename_date_format %q: %%%c is not supported", format, c)
+		}
+		pattern.WriteString(re)
+		layout.WriteString(strftimeLayouts[c])
+	}
+	re, err := regexp.Compile(pattern.String())
+	if err != nil {
+		return nil, "", err
+	}
+	return re, layout.String(), nil
+}
+
+// dateBound is a filename_before or filename_after reference: a fixed date,
+// or a number of days before now.
+type dateBound struct {
+	date    time.Time
+	daysAgo float64
+	set     bool
+}
+
+// parseDateBound parses "2024-01-31" or "30d".
+func parseDateBound(s string) (dateBound, error) {
+	if days, ok := strings.CutSuffix(s, "d"); ok {
+		n, err := strconv.ParseFloat(days, 64)
+		if err != nil || n < 0 {
+			return dateBound{}, fmt.Errorf("invalid date %q, want YYYY-MM-DD or a number of days like 30d", s)
+		}
+		return dateBound{daysAgo: n, set: true}, nil
+	}
+	date, err := time.ParseInLocation("2006-01-02", s, time.Local)
+	if err != nil {
+		return dateBound{}, fmt.Errorf("invalid date %q, want YYYY-MM-DD or a number of days like 30d", s)
+	}
+	return dateBound{date: date, set: true}, nil
+}
+
+// relative reports whether the bound is a number of days ago, which moves
+// with the current time.
+func (b dateBound) relative() bool {
+	return b.set && b.date.IsZero()
+}
+
+func (b dateBound) time() time.Time {
+	if b.date.IsZero() {
+		return clock.Now().Add(-time.Duration(b.daysAgo * float64(24*time.Hour)))
+	}
+	return b.date
+}
+
+// filenameDate finds and parses the filename_date_format date in filename.
+// The first occurrence that parses is used.
+func filenameDate(filename string, dest Destination) (time.Time, bool) {
+	for _, match := range dest.filenameDate.FindAllString(filename, -1) {
+		if t, err := time.ParseInLocation(dest.filenameLayout, match, time.Local); err == nil {
+			return t, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// matchesFilenameDate reports whether filename contains a date in dest's
+// filename_date_format within filename_before and filename_after. Names
+// without such a date never match.
+func matchesFilenameDate(filename string, dest Destination) bool {
+	t, ok := filenameDate(filename, dest)
+	if !ok {
+		return false
+	}
+	if dest.filenameBefore.set && !t.Before(dest.filenameBefore.time()) {
+		return false
+	}
+	if dest.filenameAfter.set && !t.After(dest.filenameAfter.time()) {
+		return false
+	}
+	return true
+}
@@ -0,0 +1,76 @@
+package organize
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCompileFilenameDate(t *testing.T) {
+	tests := []struct {
+		format   string
+		filename string
+		want     time.Time
+		wantOK   bool
+	}{
+		{"%Y-%m-%d", "scan_2024-03-15.pdf", time.Date(2024, 3, 15, 0, 0, 0, 0, time.Local), true},
+		{"%Y%m%d", "IMG_20240315_1200.jpg", time.Date(2024, 3, 15, 0, 0, 0, 0, time.Local), true},
+		{"%d.%m.%y", "Rechnung 15.03.24.pdf", time.Date(2024, 3, 15, 0, 0, 0, 0, time.Local), true},
+		{"%d %b %Y", "statement 05 Mar 2024.pdf", time.Date(2024, 3, 5, 0, 0, 0, 0, time.Local), true},
+		{"%Y-%m-%d", "scan_2024-13-45.pdf", time.Time{}, false},
+		{"%Y-%m-%d", "scan.pdf", time.Time{}, false},
+		{"%Y-%m-%d", "v1_2024-13-01_2024-02-29.pdf", time.Date(2024, 2, 29, 0, 0, 0, 0, time.Local), true},
+	}
+	for _, tt := range tests {
+		re, layout, err := compileFilenameDate(tt.format)
+		if err != nil {
+			t.Errorf("compileFilenameDate(%q): %v", tt.format, err)
+			continue
+		}
+		got, ok := filenameDate(tt.filename, Destination{filenameDate: re, filenameLayout: layout})
+		if ok != tt.wantOK || !got.Equal(tt.want) {
+			t.Errorf("date of %q with %q = %v, %v; want %v, %v", tt.filename, tt.format, got, ok, tt.want, tt.wantOK)
+		}
+	}
+}
+
+func TestCompileFilenameDateErrors(t *testing.T) {
+	for _, format := range []string{"%Y-%m-%", "%Y-%q"} {
+		if _, _, err := compileFilenameDate(format); err == nil {
+			t.Errorf("compileFilenameDate(%q) succeeded, want an error", format)
+		}
+	}
+}
+
+func TestParseDateBound(t *testing.T) {
+	now := time.Date(2024, 6, 30, 12, 0, 0, 0, time.Local)
+	useFakeClock(t, now)
+	tests := []struct {
+		in      string
+		want    time.Time
+		wantErr bool
+	}{
+		{in: "2024-01-31", want: time.Date(2024, 1, 31, 0, 0, 0, 0, time.Local)},
+		{in: "30d", want: now.AddDate(0, 0, -30)},
+		{in: "0.5d", want: now.Add(-12 * time.Hour)},
+		{in: "0d", want: now},
+		{in: "-1d", wantErr: true},
+		{in: "xd", wantErr: true},
+		{in: "31/01/2024", wantErr: true},
+	}
+	for _, tt := range tests {
+		bound, err := parseDateBound(tt.in)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("parseDateBound(%q) succeeded, want an error", tt.in)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("parseDateBound(%q): %v", tt.in, err)
+			continue
+		}
+		if got := bound.time(); !got.Equal(tt.want) {
+			t.Errorf("parseDateBound(%q).time() = %v, want %v", tt.in, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,30 @@
+package organize
+
+import (
+	"log"
+	"os"
+	"sync"
+	"time"
+)
+
+const (
+	timeSourceMtime = "mtime"
+	timeSourceBtime = "btime"
+)
+
+var btimeWarning sync.Once
+
+// fileTime returns the timestamp used for date-based routing. With the btime
+// source it prefers the file's birth time, falling back to mtime when the
+// platform or filesystem doesn't record one.
+func fileTime(path string, info os.FileInfo, source string) time.Time {
+	if source == timeSourceBtime {
+		if t, ok := birthTime(path, info); ok {
+			return t
+		}
+		btimeWarning.Do(func() {
+			log.Printf("Birth time is unavailable for %s, falling back to mtime", path)
+		})
+	}
+	return info.ModTime()
+}
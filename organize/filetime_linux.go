@@ -0,0 +1,21 @@
+//go:build linux
+
+package organize
+
+import (
+	"os"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+func birthTime(path string, info os.FileInfo) (time.Time, bool) {
+	var stat unix.Statx_t
+	if err := unix.Statx(unix.AT_FDCWD, path, unix.AT_SYMLINK_NOFOLLOW, unix.STATX_BTIME, &stat); err != nil {
+		return time.Time{}, false
+	}
+	if stat.Mask&unix.STATX_BTIME == 0 {
+		return time.Time{}, false
+	}
+	return time.Unix(stat.Btime.Sec, int64(stat.Btime.Nsec)), true
+}
@@ -0,0 +1,12 @@
+//go:build !darwin && !freebsd && !netbsd && !linux
+
+package organize
+
+import (
+	"os"
+	"time"
+)
+
+func birthTime(path string, info os.FileInfo) (time.Time, bool) {
+	return time.Time{}, false
+}
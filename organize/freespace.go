@@ -0,0 +1,73 @@
+package organize
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// errInsufficientSpace reports that filing a file would leave its
+// destination filesystem with less than min_free_space available.
+var errInsufficientSpace = errors.New("insufficient space")
+
+// sizeUnits maps the unit suffixes accepted by parseSize to their size.
+// Both decimal (KB, MB, ...) and binary (KiB, MiB, ...) units are accepted.
+var sizeUnits = []struct {
+	suffix string
+	size   float64
+}{
+	{"kib", 1 << 10}, {"mib", 1 << 20}, {"gib", 1 << 30}, {"tib", 1 << 40},
+	{"kb", 1e3}, {"mb", 1e6}, {"gb", 1e9}, {"tb", 1e12},
+	{"k", 1 << 10}, {"m", 1 << 20}, {"g", 1 << 30}, {"t", 1 << 40},
+	{"b", 1},
+}
+
+// parseSize parses a human-readable size such as "10GB", "512 MiB" or
+// "1.5g" into bytes. A bare number is a byte count.
+func parseSize(s string) (int64, error) {
+	value := strings.ToLower(strings.TrimSpace(s))
+	multiplier := 1.0
+	for _, unit := range sizeUnits {
+		if strings.HasSuffix(value, unit.suffix) {
+			value = strings.TrimSpace(strings.TrimSuffix(value, unit.suffix))
+			multiplier = unit.size
+			break
+		}
+	}
+	n, err := strconv.ParseFloat(value, 64)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("invalid size %q", s)
+	}
+	return int64(n * multiplier), nil
+}
+
+// checkFreeSpace returns errInsufficientSpace if writing size bytes under
+// dir would leave less than min bytes free. Where free space can't be
+// determined the check passes.
+func checkFreeSpace(dir string, size, min int64) error {
+	// The destination directory may not exist yet; measure its nearest
+	// existing parent, which is where it will be created.
+	for {
+		if _, err := os.Stat(dir); err == nil {
+			break
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return nil
+		}
+		dir = parent
+	}
+
+	free, ok := freeSpace(dir)
+	if !ok {
+		return nil
+	}
+	if int64(free)-size < min {
+		return fmt.Errorf("%w: %s free on %s, need %s plus min_free_space %s",
+			errInsufficientSpace, formatBytes(int64(free)), dir, formatBytes(size), formatBytes(min))
+	}
+	return nil
+}
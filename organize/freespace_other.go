@@ -0,0 +1,9 @@
+//go:build !darwin && !freebsd && !linux
+
+package organize
+
+// freeSpace is not implemented on this platform, so min_free_space is not
+// enforced.
+func freeSpace(path string) (uint64, bool) {
+	return 0, false
+}
@@ -0,0 +1,74 @@
+package organize
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// compileGlob turns a shell-style glob into an anchored regexp. Besides
+// * and ? (which don't cross a /) it supports ** (which does), character
+// classes such as [a-z] and [!0-9], and alternatives such as {jpg,png}.
+func compileGlob(glob string, caseSensitive bool) (*regexp.Regexp, error) {
+	var re strings.Builder
+	if !caseSensitive {
+		re.WriteString("(?i)")
+	}
+	re.WriteString("^")
+
+	depth := 0 // open { groups
+	for i := 0; i < len(glob); i++ {
+		c := glob[i]
+		switch {
+		case c == '*' && i+1 < len(glob) && glob[i+1] == '*':
+			re.WriteString(".*")
+			i++
+		case c == '*':
+			re.WriteString("[^/]*")
+		case c == '?':
+			re.WriteString("[^/]")
+		case c == '[':
+			end := strings.IndexByte(glob[i+1:], ']')
+			if end == 0 && i+2 < len(glob) {
+				// a ] right after the [ is part of the class
+				if next := strings.IndexByte(glob[i+2:], ']'); next >= 0 {
+					end = next + 1
+				} else {
+					end = -1
+				}
+			}
+			if end < 0 {
+				return nil, fmt.Errorf("pattern %q has an unclosed [", glob)
+			}
+			class := glob[i+1 : i+1+end]
+			if class != "" && class[0] == '!' {
+				class = "^" + class[1:]
+			}
+			re.WriteString("[" + strings.ReplaceAll(class, `\`, `\\`) + "]")
+			i += end + 1
+		case c == '{':
+			re.WriteString("(?:")
+			depth++
+		case c == '}' && depth > 0:
+			re.WriteString(")")
+			depth--
+		case c == ',' && depth > 0:
+			re.WriteString("|")
+		case c == '\\' && i+1 < len(glob):
+			re.WriteString(regexp.QuoteMeta(glob[i+1 : i+2]))
+			i++
+		default:
+			re.WriteString(regexp.QuoteMeta(glob[i : i+1]))
+		}
+	}
+	if depth > 0 {
+		return nil, fmt.Errorf("pattern %q has an unclosed {", glob)
+	}
+	re.WriteString("$")
+
+	compiled, err := regexp.Compile(re.String())
+	if err != nil {
+		return nil, fmt.Errorf("invalid pattern %q: %w", glob, err)
+	}
+	return compiled, nil
+}
@@ -0,0 +1,49 @@
+package organize
+
+import "testing"
+
+func TestCompileGlob(t *testing.T) {
+	tests := []struct {
+		glob          string
+		caseSensitive bool
+		name          string
+		want          bool
+	}{
+		{"IMG_*.jpg", true, "IMG_0001.jpg", true},
+		{"IMG_*.jpg", true, "img_0001.jpg", false},
+		{"IMG_*.jpg", false, "img_0001.JPG", true},
+		{"*.pdf", true, "dir/a.pdf", false},
+		{"**.pdf", true, "dir/a.pdf", true},
+		{"report-????.pdf", true, "report-2024.pdf", true},
+		{"report-????.pdf", true, "report-24.pdf", false},
+		{"report-[0-9][0-9].pdf", true, "report-07.pdf", true},
+		{"report-[!0-9]*.pdf", true, "report-07.pdf", false},
+		{"report-[!0-9]*.pdf", true, "report-final.pdf", true},
+		{"[]]x", true, "]x", true},
+		{"IMG_*.{jpg,png}", true, "IMG_1.png", true},
+		{"IMG_*.{jpg,png}", true, "IMG_1.gif", false},
+		{"a.b+c(1)", true, "a.b+c(1)", true},
+		{"a.b", true, "axb", false},
+		{"a}b,c", true, "a}b,c", true},
+		{`\*.txt`, true, "*.txt", true},
+		{`\*.txt`, true, "a.txt", false},
+	}
+	for _, tt := range tests {
+		re, err := compileGlob(tt.glob, tt.caseSensitive)
+		if err != nil {
+			t.Errorf("compileGlob(%q): %v", tt.glob, err)
+			continue
+		}
+		if got := re.MatchString(tt.name); got != tt.want {
+			t.Errorf("compileGlob(%q, %v) matches %q = %v, want %v", tt.glob, tt.caseSensitive, tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestCompileGlobErrors(t *testing.T) {
+	for _, glob := range []string{"[a-z", "{jpg,png"} {
+		if _, err := compileGlob(glob, true); err == nil {
+			t.Errorf("compileGlob(%q) succeeded, want an error", glob)
+		}
+	}
+}
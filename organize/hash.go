@@ -0,0 +1,49 @@
+package organize
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+)
+
+// fileSHA256 returns the hex-encoded SHA-256 of the file's content.
+func fileSHA256(path string) (string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to open file for hashing: %w", err)
+	}
+	defer file.Close()
+
+	hash := sha256.New()
+	if _, err := io.Copy(hash, file); err != nil {
+		return "", fmt.Errorf("failed to hash file: %w", err)
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// sameContent reports whether the two files have identical content.
+func sameContent(pathA, pathB string) (bool, error) {
+	infoA, err := os.Stat(pathA)
+	if err != nil {
+		return false, err
+	}
+	infoB, err := os.Stat(pathB)
+	if err != nil {
+		return false, err
+	}
+	if infoA.Size() != infoB.Size() {
+		return false, nil
+	}
+
+	hashA, err := fileSHA256(pathA)
+	if err != nil {
+		return false, err
+	}
+	hashB, err := fileSHA256(pathB)
+	if err != nil {
+		return false, err
+	}
+	return hashA == hashB, nil
+}
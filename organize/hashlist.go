@@ -0,0 +1,116 @@
+package organize
+
+import (
+	"bufio"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// readHashList reads a file of SHA-256 hashes, one per line in hex as
+// printed by sha256sum. Anything after the hash on a line (such as
+// sha256sum's file name), blank lines and lines starting with # are
+// ignored.
+func readHashList(path string) (map[string]bool, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	hashes := make(map[string]bool)
+	scanner := bufio.NewScanner(file)
+	for line := 1; scanner.Scan(); line++ {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 || strings.HasPrefix(fields[0], "#") {
+			continue
+		}
+		hash := strings.ToLower(fields[0])
+		if decoded, err := hex.DecodeString(hash); err != nil || len(decoded) != 32 {
+			return nil, fmt.Errorf("%s:%d: %q is not a SHA-256 hash", path, line, fields[0])
+		}
+		hashes[hash] = true
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return hashes, nil
+}
+
+// loadHashLists reads hash_denylist and hash_allowlist. Relative paths are
+// resolved against dir, the directory the config file is in.
+func (c *Config) loadHashLists(dir string) error {
+	load := func(option, path string) (map[string]bool, error) {
+		if path == "" {
+			return nil, nil
+		}
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(dir, path)
+		}
+		c.includes = append(c.includes, filepath.Clean(path))
+		hashes, err := readHashList(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", option, err)
+		}
+		return hashes, nil
+	}
+
+	var errDeny, errAllow error
+	c.hashDenylist, errDeny = load("hash_denylist", c.HashDenylist)
+	c.hashAllowlist, errAllow = load("hash_allowlist", c.HashAllowlist)
+	return errors.Join(errDeny, errAllow)
+}
+
+// checkHashLists decides what happens to a matched file based on its
+// content hash: files on the denylist are deleted, and with an allowlist
+// only files on it are filed. It reports whether the file should still be
+// filed.
+func (p *passState) checkHashLists(sourcePath string) bool {
+	config := p.config
+	if config.hashDenylist == nil && config.hashAllowlist == nil {
+		return true
+	}
+	if isDirectory(sourcePath) {
+		if config.hashAllowlist == nil {
+			return true
+		}
+		log.Printf("Not filing %s: a directory can't be on hash_allowlist", sourcePath)
+		events.publish(Event{Type: eventSkipped, Source: sourcePath})
+		p.skipped()
+		return false
+	}
+
+	hash, err := fileSHA256(sourcePath)
+	if err != nil {
+		log.Printf("Not filing %s: %v", sourcePath, err)
+		events.publish(Event{Type: eventError, Source: sourcePath, Error: err.Error()})
+		p.skipped()
+		p.errored()
+		return false
+	}
+
+	if config.hashDenylist[hash] {
+		log.Printf("Deleting %s: its hash is on hash_denylist", sourcePath)
+		if err := os.Remove(sourcePath); err != nil {
+			log.Printf("failed to delete denylisted file: %v", err)
+			events.publish(Event{Type: eventError, Source: sourcePath, Error: err.Error()})
+			p.errored()
+			return false
+		}
+		events.publish(Event{Type: eventDeleted, Source: sourcePath})
+		p.deleted()
+		return false
+	}
+
+	if config.hashAllowlist != nil && !config.hashAllowlist[hash] {
+		log.Printf("Not filing %s: its hash is not on hash_allowlist", sourcePath)
+		events.publish(Event{Type: eventSkipped, Source: sourcePath})
+		p.skipped()
+		return false
+	}
+	return true
+}
@@ -0,0 +1,109 @@
+package organize
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func sha256Hex(s string) string {
+	sum := sha256.Sum256([]byte(s))
+	return hex.EncodeToString(sum[:])
+}
+
+func TestHashLists(t *testing.T) {
+	tests := []struct {
+		name      string
+		denylist  string
+		allowlist string
+		wantMoved []string
+		wantLeft  []string
+		deleted   int
+		skipped   int
+	}{
+		{
+			name:      "denylist",
+			denylist:  "# tracking pixels\n" + sha256Hex("pixel") + "  a_pixel.gif\n",
+			wantMoved: []string{"a_photo.jpg", "a_doc.pdf"},
+			deleted:   1,
+		},
+		{
+			name:      "allowlist",
+			allowlist: strings.ToUpper(sha256Hex("photo")) + "\n",
+			wantMoved: []string{"a_photo.jpg"},
+			wantLeft:  []string{"a_pixel.gif", "a_doc.pdf"},
+			skipped:   2,
+		},
+		{
+			name:      "denylist wins over allowlist",
+			denylist:  sha256Hex("pixel") + "\n",
+			allowlist: sha256Hex("pixel") + "\n" + sha256Hex("doc") + "\n",
+			wantMoved: []string{"a_doc.pdf"},
+			wantLeft:  []string{"a_photo.jpg"},
+			deleted:   1,
+			skipped:   1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			dump := filepath.Join(dir, "dump")
+			out := filepath.Join(dir, "out")
+			for name, content := range map[string]string{"a_pixel.gif": "pixel", "a_photo.jpg": "photo", "a_doc.pdf": "doc"} {
+				writeFile(t, filepath.Join(dump, name), content, time.Time{})
+			}
+			config := &Config{DumpDirectory: dump, Destinations: []Destination{{Prefix: "a_", Path: out}}}
+			if tt.denylist != "" {
+				writeFile(t, filepath.Join(dir, "deny.txt"), tt.denylist, time.Time{})
+				config.HashDenylist = "deny.txt"
+			}
+			if tt.allowlist != "" {
+				writeFile(t, filepath.Join(dir, "allow.txt"), tt.allowlist, time.Time{})
+				config.HashAllowlist = filepath.Join(dir, "allow.txt")
+			}
+			if err := config.loadHashLists(dir); err != nil {
+				t.Fatal(err)
+			}
+			if err := config.compile(); err != nil {
+				t.Fatal(err)
+			}
+			result, err := organizeWith(config, 1)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if result.Moved != len(tt.wantMoved) || result.Deleted != tt.deleted || result.Skipped != tt.skipped {
+				t.Errorf("moved %d, deleted %d, skipped %d, want %d, %d, %d", result.Moved, result.Deleted, result.Skipped, len(tt.wantMoved), tt.deleted, tt.skipped)
+			}
+			for _, name := range tt.wantMoved {
+				if _, err := os.Lstat(filepath.Join(out, name)); err != nil {
+					t.Errorf("%s not filed: %v", name, err)
+				}
+			}
+			for _, name := range tt.wantLeft {
+				if _, err := os.Lstat(filepath.Join(dump, name)); err != nil {
+					t.Errorf("%s not left in the dump directory: %v", name, err)
+				}
+			}
+			if _, err := os.Lstat(filepath.Join(dump, "a_pixel.gif")); tt.deleted > 0 && !os.IsNotExist(err) {
+				t.Errorf("denylisted file not deleted: %v", err)
+			}
+		})
+	}
+}
+
+func TestReadHashListErrors(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "deny.txt")
+	writeFile(t, path, sha256Hex("ok")+"\nd41d8cd98f00b204e9800998ecf8427e\n", time.Time{})
+	if _, err := readHashList(path); err == nil || !strings.Contains(err.Error(), "deny.txt:2") {
+		t.Errorf("readHashList with an MD5 line = %v, want an error for line 2", err)
+	}
+	config := &Config{HashDenylist: "missing.txt"}
+	if err := config.loadHashLists(dir); err == nil || !strings.Contains(err.Error(), "hash_denylist") {
+		t.Errorf("loadHashLists with a missing file = %v, want a hash_denylist error", err)
+	}
+}
@@ -0,0 +1,20 @@
+package organize
+
+// defaultIgnoreExtensions are the extensions browsers and downloaders give
+// files they are still writing, renaming them once done.
+var defaultIgnoreExtensions = []string{"crdownload", "part", "partial", "download", "tmp", "opdownload"}
+
+// ignoredExtensions returns ignore_extensions, or the built-in list when it
+// isn't set.
+func (c *Config) ignoredExtensions() []string {
+	if c.IgnoreExtensions == nil {
+		return defaultIgnoreExtensions
+	}
+	return c.IgnoreExtensions
+}
+
+// ignored reports whether filename is an in-progress download or temporary
+// file that is never organized, whatever the rules say.
+func (c *Config) ignored(filename string) bool {
+	return matchesExtension(filename, c.ignoredExtensions())
+}
@@ -0,0 +1,42 @@
+package organize
+
+import (
+	"image"
+	_ "image/gif"
+	_ "image/jpeg"
+	_ "image/png"
+	"os"
+)
+
+func hasDimensionConditions(dest Destination) bool {
+	return dest.MinWidth > 0 || dest.MinHeight > 0 || dest.MaxWidth > 0 || dest.MaxHeight > 0
+}
+
+// imageSize returns the dimensions of the PNG, JPEG or GIF image at path,
+// reading only its header.
+func imageSize(path string) (width, height int, ok bool) {
+	file, err := os.Open(path)
+	if err != nil {
+		return 0, 0, false
+	}
+	defer file.Close()
+
+	config, _, err := image.DecodeConfig(file)
+	if err != nil {
+		return 0, 0, false
+	}
+	return config.Width, config.Height, true
+}
+
+// matchesDimensions reports whether the file at path is an image within
+// dest's size bounds. Files that aren't images never match.
+func matchesDimensions(path string, dest Destination) bool {
+	width, height, ok := imageSize(path)
+	if !ok {
+		return false
+	}
+	return (dest.MinWidth == 0 || width >= dest.MinWidth) &&
+		(dest.MinHeight == 0 || height >= dest.MinHeight) &&
+		(dest.MaxWidth == 0 || width <= dest.MaxWidth) &&
+		(dest.MaxHeight == 0 || height <= dest.MaxHeight)
+}
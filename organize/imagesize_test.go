@@ -0,0 +1,46 @@
+package organize
+
+import (
+	"image"
+	"image/png"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// writePNG writes a blank width by height PNG to path.
+func writePNG(t *testing.T, path string, width, height int) {
+	t.Helper()
+	file, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	if err := png.Encode(file, image.NewGray(image.Rect(0, 0, width, height))); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestImageDimensions(t *testing.T) {
+	dir := t.TempDir()
+	config := &Config{DumpDirectory: dir, Destinations: []Destination{
+		{Suffix: ".png", MinWidth: 1920, MinHeight: 1080, Path: filepath.Join(dir, "wallpapers")},
+		{Suffix: ".png", MaxWidth: 64, MaxHeight: 64, Path: filepath.Join(dir, "icons")},
+	}}
+	if err := config.compile(); err != nil {
+		t.Fatal(err)
+	}
+	writePNG(t, filepath.Join(dir, "large.png"), 2560, 1440)
+	writePNG(t, filepath.Join(dir, "small.png"), 32, 32)
+	writePNG(t, filepath.Join(dir, "medium.png"), 800, 600)
+	writePNG(t, filepath.Join(dir, "wide.png"), 1920, 64)
+	writeFile(t, filepath.Join(dir, "fake.png"), "not an image", time.Time{})
+
+	for name, want := range map[string]int{"large.png": 0, "small.png": 1, "medium.png": -1, "wide.png": -1, "fake.png": -1} {
+		path := filepath.Join(dir, name)
+		if got, _, err := config.route(path, name); err != nil || got != want {
+			t.Errorf("route(%q) = %d, %v, want %d", name, got, err, want)
+		}
+	}
+}
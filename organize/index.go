@@ -0,0 +1,118 @@
+package organize
+
+// destinationIndex speeds up first-match lookup when there are many rules.
+// Prefix-only rules are stored in a byte trie so a filename only visits the
+// rules whose prefix it actually starts with; every other rule is checked
+// with matchesPattern in config order.
+type destinationIndex struct {
+	prefixes *prefixNode
+	others   []int
+}
+
+type prefixNode struct {
+	children map[byte]*prefixNode
+	rules    []int
+}
+
+func newDestinationIndex(destinations []Destination) *destinationIndex {
+	idx := &destinationIndex{prefixes: &prefixNode{}}
+	for i, dest := range destinations {
+		if !isPrefixOnly(dest) {
+			idx.others = append(idx.others, i)
+			continue
+		}
+
+		node := idx.prefixes
+		for j := 0; j < len(dest.Prefix); j++ {
+			if node.children == nil {
+				node.children = make(map[byte]*prefixNode)
+			}
+			child, ok := node.children[dest.Prefix[j]]
+			if !ok {
+				child = &prefixNode{}
+				node.children[dest.Prefix[j]] = child
+			}
+			node = child
+		}
+		node.rules = append(node.rules, i)
+	}
+	return idx
+}
+
+// isPrefixOnly reports whether dest matches on its prefix alone, which is
+// what allows it to live in the trie.
+func isPrefixOnly(dest Destination) bool {
+	return dest.Prefix != "" && dest.Suffix == "" && len(dest.Exact) == 0 && len(dest.Extensions) == 0 && dest.Pattern == "" && dest.Regex == "" && dest.FilenameDateFormat == "" && isCaseSensitive(dest.PrefixCaseSensitive) && !dest.MatchStem
+}
+
+// firstMatch returns the index of the first destination (in config order)
+// that matches filename, or -1 if none do.
+func (idx *destinationIndex) firstMatch(filename string, destinations []Destination) int {
+	best := -1
+
+	node := idx.prefixes
+	for i := 0; node != nil; i++ {
+		for _, rule := range node.rules {
+			if best == -1 || rule < best {
+				best = rule
+			}
+		}
+		if i == len(filename) {
+			break
+		}
+		node = node.children[filename[i]]
+	}
+
+	for _, rule := range idx.others {
+		if best != -1 && rule > best {
+			break
+		}
+		if matchesPattern(filename, destinations[rule]) {
+			return rule
+		}
+	}
+
+	return best
+}
+
+// buildIndex (re)builds the lookup index.
+func (c *Config) buildIndex() {
+	c.index = newDestinationIndex(c.Destinations)
+}
+
+// matchDestination returns the index of the destination filename routes to.
+func (c *Config) matchDestination(filename string) (int, bool) {
+	if c.index == nil {
+		for i, dest := range c.Destinations {
+			if matchesPattern(filename, dest) {
+				return i, true
+			}
+		}
+		return -1, false
+	}
+
+	i := c.index.firstMatch(filename, c.Destinations)
+	return i, i != -1
+}
+
+// Classify returns the destination filename would be routed to, applying the
+// same first-match rules as an organize pass, without touching the filesystem.
+// Conditions on file content (such as magic) are not checked.
+func (c *Config) Classify(filename string) (Destination, bool) {
+	i, ok := c.matchDestination(filename)
+	if !ok {
+		return Destination{}, false
+	}
+	return c.Destinations[i], true
+}
+
+// matchDestinationAfter returns the next destination after index after that
+// matches filename, for when a matching destination turns out not to apply.
+func (c *Config) matchDestinationAfter(filename string, after int) (int, bool) {
+	for i := after + 1; i < len(c.Destinations); i++ {
+		if matchesPattern(filename, c.Destinations[i]) {
+			return i, true
+		}
+	}
+	return -1, false
+}
@@ -0,0 +1,58 @@
+package organize
+
+import (
+	"fmt"
+	"testing"
+)
+
+// classifyFixture returns a config with 200 rules, three quarters of them
+// prefix-only, and 1000 file names of which most match one of them.
+func classifyFixture(tb testing.TB) (*Config, []string) {
+	tb.Helper()
+	out := tb.TempDir()
+	config := &Config{DumpDirectory: tb.TempDir()}
+	for i := 0; i < 200; i++ {
+		dest := Destination{Path: out, Prefix: fmt.Sprintf("p%03d_", i)}
+		if i%4 == 3 {
+			dest = Destination{Path: out, Suffix: fmt.Sprintf(".s%03d", i)}
+		}
+		config.Destinations = append(config.Destinations, dest)
+	}
+	if err := config.compile(); err != nil {
+		tb.Fatal(err)
+	}
+
+	names := make([]string, 1000)
+	for i := range names {
+		switch i % 3 {
+		case 0:
+			names[i] = fmt.Sprintf("p%03d_file%d.txt", i%200, i)
+		case 1:
+			names[i] = fmt.Sprintf("file%d.s%03d", i, (i%50)*4+3)
+		default:
+			names[i] = fmt.Sprintf("unmatched%d.bin", i)
+		}
+	}
+	return config, names
+}
+
+func TestClassifyMatchesLinearScan(t *testing.T) {
+	config, names := classifyFixture(t)
+	for _, name := range names {
+		want, wantOK := config.matchDestinationAfter(name, -1)
+		got, ok := config.matchDestination(name)
+		if got != want || ok != wantOK {
+			t.Errorf("matchDestination(%q) = %d, %v; linear scan gives %d, %v", name, got, ok, want, wantOK)
+		}
+	}
+}
+
+func BenchmarkClassify(b *testing.B) {
+	config, names := classifyFixture(b)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		for _, name := range names {
+			config.Classify(name)
+		}
+	}
+}
@@ -0,0 +1,171 @@
+package organize
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// versionsMu serializes updates to the versions file, which every worker
+// filing to a keep_newest_matching rule reads and rewrites.
+var versionsMu sync.Mutex
+
+// versionsPath returns the file recording what keep_newest_matching rules
+// have filed, ~/.config/prefix/versions.json.
+func versionsPath() (string, error) {
+	dir, err := configDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "versions.json"), nil
+}
+
+// trashDir returns where keep_newest_matching moves older versions,
+// ~/.config/prefix/trash.
+func trashDir() (string, error) {
+	dir, err := configDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "trash"), nil
+}
+
+// keepNewest records destPath as filed by rule and moves the older versions
+// that rule filed to the same directory into the trash, keeping only the
+// most recently modified one, so that e.g. only the newest app-*.dmg is
+// kept. Only files the rule itself filed are considered, so nothing else in
+// the directory is touched, and destPath is never removed: if it is older
+// than a version already there, both are kept.
+func keepNewest(config *Config, rule int, destPath string) {
+	versionsMu.Lock()
+	defer versionsMu.Unlock()
+
+	path, err := versionsPath()
+	if err != nil {
+		log.Printf("failed to locate versions file: %v", err)
+		return
+	}
+	filed, err := readVersions(path)
+	if err != nil {
+		log.Printf("failed to read versions file, not removing older versions: %v", err)
+		return
+	}
+
+	label := config.ruleLabel(rule)
+	dir := filepath.Dir(destPath)
+	var kept, versions []string
+	for _, version := range filed[label] {
+		if version == destPath {
+			continue
+		}
+		if _, err := os.Lstat(version); err != nil {
+			continue
+		}
+		if filepath.Dir(version) == dir {
+			versions = append(versions, version)
+		} else {
+			kept = append(kept, version)
+		}
+	}
+
+	newest := destPath
+	newestTime := modTime(destPath)
+	for _, version := range versions {
+		if t := modTime(version); t.After(newestTime) {
+			newest, newestTime = version, t
+		}
+	}
+	for _, version := range versions {
+		if version == newest {
+			kept = append(kept, version)
+			continue
+		}
+		trashed, err := moveToTrash(config, version)
+		if err != nil {
+			log.Printf("failed to move older version %s to the trash: %v", version, err)
+			kept = append(kept, version)
+			continue
+		}
+		log.Printf("Moved older version to the trash: %s -> %s (keeping %s)", version, trashed, filepath.Base(newest))
+	}
+
+	filed[label] = append(kept, destPath)
+	if err := writeVersions(path, filed); err != nil {
+		log.Printf("failed to write versions file: %v", err)
+	}
+}
+
+func modTime(path string) (t time.Time) {
+	if info, err := os.Lstat(path); err == nil {
+		t = info.ModTime()
+	}
+	return t
+}
+
+// moveToTrash moves the file at path, and the files filed along with it,
+// into the trash directory, numbering it if the name is already taken.
+func moveToTrash(config *Config, path string) (string, error) {
+	dir, err := trashDir()
+	if err != nil {
+		return "", err
+	}
+	trashed := filepath.Join(dir, filepath.Base(path))
+	for n := 1; ; n++ {
+		if _, err := os.Lstat(trashed); os.IsNotExist(err) {
+			break
+		}
+		trashed = numberedPath(filepath.Join(dir, filepath.Base(path)), n)
+	}
+	if err := moveFile(path, trashed); err != nil {
+		return "", err
+	}
+
+	for _, suffix := range []string{config.SidecarSuffix, encryptedHashSuffix} {
+		if suffix == "" {
+			continue
+		}
+		if _, err := os.Lstat(path + suffix); err != nil {
+			continue
+		}
+		if err := moveFile(path+suffix, trashed+suffix); err != nil {
+			log.Printf("failed to move %s to the trash: %v", path+suffix, err)
+		}
+	}
+	return trashed, nil
+}
+
+// readVersions reads the files each keep_newest_matching rule has filed,
+// keyed by rule label. A missing file is an empty record.
+func readVersions(path string) (map[string][]string, error) {
+	filed := make(map[string][]string)
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return filed, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, &filed); err != nil {
+		return nil, err
+	}
+	return filed, nil
+}
+
+func writeVersions(path string, filed map[string][]string) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(filed, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
@@ -0,0 +1,62 @@
+package organize
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestKeepNewest(t *testing.T) {
+	base := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name    string
+		filed   []time.Time // modification times, filed in this order
+		kept    []int       // indexes of files left in the destination
+		trashed []int
+	}{
+		{"newer replaces older", []time.Time{base, base.Add(time.Hour)}, []int{1}, []int{0}},
+		{"older filed last is kept", []time.Time{base.Add(time.Hour), base}, []int{0, 1}, nil},
+		{"only the newest of several", []time.Time{base, base.Add(time.Hour), base.Add(2 * time.Hour)}, []int{2}, []int{0, 1}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			home := t.TempDir()
+			t.Setenv("HOME", home)
+			out := filepath.Join(home, "out")
+			config := &Config{SidecarSuffix: ".meta.json", Destinations: []Destination{{Name: "apps", KeepNewestMatching: true}}}
+
+			// An unrelated file matching the same name pattern is never touched.
+			writeFile(t, filepath.Join(out, "app-0.dmg"), "other", base.Add(-time.Hour))
+			var paths []string
+			for i, mtime := range tt.filed {
+				path := filepath.Join(out, "app-"+string(rune('1'+i))+".dmg")
+				writeFile(t, path, "v", mtime)
+				writeFile(t, path+config.SidecarSuffix, "{}", mtime)
+				paths = append(paths, path)
+				keepNewest(config, 0, path)
+			}
+
+			if _, err := os.Lstat(filepath.Join(out, "app-0.dmg")); err != nil {
+				t.Errorf("unrelated file removed: %v", err)
+			}
+			for _, i := range tt.kept {
+				if _, err := os.Lstat(paths[i]); err != nil {
+					t.Errorf("%s removed, want it kept", filepath.Base(paths[i]))
+				}
+			}
+			for _, i := range tt.trashed {
+				name := filepath.Base(paths[i])
+				if _, err := os.Lstat(paths[i]); !os.IsNotExist(err) {
+					t.Errorf("%s kept, want it trashed", name)
+				}
+				if _, err := os.Lstat(filepath.Join(home, ".config", "prefix", "trash", name)); err != nil {
+					t.Errorf("%s not in the trash: %v", name, err)
+				}
+				if _, err := os.Lstat(filepath.Join(home, ".config", "prefix", "trash", name+config.SidecarSuffix)); err != nil {
+					t.Errorf("sidecar of %s not in the trash: %v", name, err)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,78 @@
+package organize
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// errLocked reports that another instance holds the instance lock.
+var errLocked = errors.New("another instance is already running")
+
+// instanceLock keeps a second organizer from running at the same time,
+// since two would race on every move.
+type instanceLock struct {
+	file *os.File
+	path string
+}
+
+// acquireInstanceLock takes ~/.config/prefix/prefix.lock and records this
+// process's PID in it. If another instance holds the lock the error wraps
+// errLocked and names its PID.
+func acquireInstanceLock() (*instanceLock, error) {
+	dir, err := configDir()
+	if err != nil {
+		return nil, err
+	}
+	path := filepath.Join(dir, "prefix.lock")
+
+	file, err := lockFile(path)
+	if errors.Is(err, errLocked) {
+		if pid := lockHolder(path); pid != 0 {
+			return nil, fmt.Errorf("%w (PID %d holds %s)", errLocked, pid, path)
+		}
+		return nil, fmt.Errorf("%w (%s is held)", errLocked, path)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to lock %s: %w", path, err)
+	}
+
+	if err := file.Truncate(0); err == nil {
+		fmt.Fprintf(file, "%d\n", os.Getpid())
+	}
+	return &instanceLock{file: file, path: path}, nil
+}
+
+// lockForCommand takes the instance lock for a subcommand that files or
+// removes files, so it can't race a running organizer. It reports on
+// stderr and returns false if the lock is held; --ignore-lock skips it.
+func lockForCommand(command string) (release func(), ok bool) {
+	if options.IgnoreLock {
+		return func() {}, true
+	}
+	lock, err := acquireInstanceLock()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "prefix %s: %v; stop it first, or pass --ignore-lock\n", command, err)
+		return nil, false
+	}
+	return lock.release, true
+}
+
+// lockHolder returns the PID recorded in the lock file, or 0.
+func lockHolder(path string) int {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return 0
+	}
+	pid, _ := strconv.Atoi(strings.TrimSpace(string(data)))
+	return pid
+}
+
+// release gives the lock up. The lock is also dropped by the kernel when
+// the process exits, except with the portable fallback.
+func (l *instanceLock) release() {
+	unlockFile(l.file, l.path)
+}
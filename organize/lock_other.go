@@ -0,0 +1,23 @@
+//go:build !unix
+
+package organize
+
+import (
+	"errors"
+	"os"
+)
+
+// lockFile creates path exclusively. Without flock the lock isn't dropped
+// when the process dies, so a crash leaves it behind until removed.
+func lockFile(path string) (*os.File, error) {
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_EXCL, 0o644)
+	if errors.Is(err, os.ErrExist) {
+		return nil, errLocked
+	}
+	return file, err
+}
+
+func unlockFile(file *os.File, path string) {
+	file.Close()
+	os.Remove(path)
+}
@@ -0,0 +1,31 @@
+//go:build unix
+
+package organize
+
+import (
+	"errors"
+	"os"
+
+	"golang.org/x/sys/unix"
+)
+
+// lockFile opens path and takes an exclusive flock on it without blocking.
+func lockFile(path string) (*os.File, error) {
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	if err := unix.Flock(int(file.Fd()), unix.LOCK_EX|unix.LOCK_NB); err != nil {
+		file.Close()
+		if errors.Is(err, unix.EWOULDBLOCK) {
+			return nil, errLocked
+		}
+		return nil, err
+	}
+	return file, nil
+}
+
+func unlockFile(file *os.File, path string) {
+	unix.Flock(int(file.Fd()), unix.LOCK_UN)
+	file.Close()
+}
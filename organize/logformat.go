@@ -0,0 +1,53 @@
+package organize
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"time"
+)
+
+const (
+	logTimestampLocal   = "local"
+	logTimestampUTC     = "utc"
+	logTimestampRFC3339 = "rfc3339"
+)
+
+func validLogTimestampFormat(format string) bool {
+	switch format {
+	case "", logTimestampLocal, logTimestampUTC, logTimestampRFC3339:
+		return true
+	}
+	return false
+}
+
+// setLogTimestampFormat switches the standard logger's timestamps to format,
+// writing to out. rfc3339 timestamps are always UTC with millisecond
+// resolution so log lines from several machines sort together.
+func setLogTimestampFormat(out io.Writer, format string) {
+	switch format {
+	case logTimestampUTC:
+		log.SetOutput(out)
+		log.SetFlags(log.Ldate | log.Ltime | log.LUTC | log.Lshortfile)
+	case logTimestampRFC3339:
+		log.SetOutput(rfc3339Writer{out})
+		log.SetFlags(log.Lshortfile)
+	default:
+		log.SetOutput(out)
+		log.SetFlags(log.Ldate | log.Ltime | log.Lshortfile)
+	}
+}
+
+// rfc3339Writer prefixes every log line with an RFC 3339 timestamp. The log
+// package calls Write once per line, so each call gets exactly one prefix.
+type rfc3339Writer struct {
+	out io.Writer
+}
+
+func (w rfc3339Writer) Write(p []byte) (int, error) {
+	stamp := time.Now().UTC().Format("2006-01-02T15:04:05.000Z07:00")
+	if _, err := fmt.Fprintf(w.out, "%s %s", stamp, p); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
@@ -0,0 +1,105 @@
+package organize
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"unicode/utf8"
+)
+
+const (
+	longPathTruncate = "truncate"
+	longPathSkip     = "skip"
+	longPathError    = "error"
+)
+
+// maxNameLength is the longest file name, in bytes, common filesystems
+// accept (NAME_MAX on Linux and macOS).
+const maxNameLength = 255
+
+// errPathTooLong reports a destination path over the platform limit when
+// on_long_path is "skip".
+var errPathTooLong = errors.New("destination path too long")
+
+// maxPathLength returns PATH_MAX for the current platform.
+func maxPathLength() int {
+	if runtime.GOOS == "darwin" {
+		return 1024
+	}
+	return 4096
+}
+
+// fitPathLength checks destPath against the platform's name and path length
+// limits. Over the limit, policy "truncate" shortens the base name while
+// keeping its extension, "error" fails, and "skip" (the default) returns
+// errPathTooLong.
+func fitPathLength(destPath, policy string) (string, error) {
+	dir, name := filepath.Split(destPath)
+	nameLimit := nameLengthLimit(dir)
+	if len(name) <= nameLimit {
+		return destPath, nil
+	}
+
+	switch policy {
+	case longPathTruncate:
+		name, err := truncateName(name, nameLimit)
+		if err != nil {
+			return "", err
+		}
+		return filepath.Join(dir, name), nil
+	case longPathError:
+		return "", fmt.Errorf("destination path exceeds the platform limit (%d-byte name, limit %d): %s", len(name), nameLimit, destPath)
+	default:
+		return "", fmt.Errorf("%w (%d-byte name, limit %d): %s", errPathTooLong, len(name), nameLimit, destPath)
+	}
+}
+
+// fitNumberedPath is numberedPath(destPath, n) checked against the length
+// limits like fitPathLength. With "truncate" the name is shortened before
+// " (n)" is added, so the number that makes it free is kept.
+func fitNumberedPath(destPath string, n int, policy string) (string, error) {
+	numbered := numberedPath(destPath, n)
+	if policy != longPathTruncate {
+		return fitPathLength(numbered, policy)
+	}
+
+	dir, name := filepath.Split(destPath)
+	nameLimit := nameLengthLimit(dir) - (len(numbered) - len(destPath))
+	if len(name) <= nameLimit {
+		return numbered, nil
+	}
+	name, err := truncateName(name, nameLimit)
+	if err != nil {
+		return "", err
+	}
+	return numberedPath(filepath.Join(dir, name), n), nil
+}
+
+// nameLengthLimit is the longest name a file in dir can have, which is
+// NAME_MAX unless dir leaves less room under PATH_MAX.
+func nameLengthLimit(dir string) int {
+	return min(maxNameLength, maxPathLength()-1-len(dir))
+}
+
+// truncateName shortens name to at most nameLimit bytes, keeping its
+// extension.
+func truncateName(name string, nameLimit int) (string, error) {
+	ext := filepath.Ext(name)
+	if len(ext) >= nameLimit {
+		return "", fmt.Errorf("cannot truncate %s to fit %d bytes", name, nameLimit)
+	}
+	return truncateUTF8(strings.TrimSuffix(name, ext), nameLimit-len(ext)) + ext, nil
+}
+
+// truncateUTF8 cuts s to at most n bytes without splitting a rune.
+func truncateUTF8(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	for n > 0 && !utf8.RuneStart(s[n]) {
+		n--
+	}
+	return s[:n]
+}
@@ -0,0 +1,64 @@
+package organize
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestFitNumberedPath(t *testing.T) {
+	dir := "/out"
+	short := filepath.Join(dir, "report.pdf")
+	atLimit := filepath.Join(dir, strings.Repeat("a", maxNameLength-len(".pdf"))+".pdf")
+
+	got, err := fitNumberedPath(short, 1, longPathSkip)
+	if err != nil || got != filepath.Join(dir, "report (1).pdf") {
+		t.Errorf("fitNumberedPath(short) = %q, %v", got, err)
+	}
+
+	if _, err := fitNumberedPath(atLimit, 1, longPathSkip); !errors.Is(err, errPathTooLong) {
+		t.Errorf("fitNumberedPath(atLimit, skip) error = %v, want errPathTooLong", err)
+	}
+	if _, err := fitNumberedPath(atLimit, 1, longPathError); err == nil || errors.Is(err, errPathTooLong) {
+		t.Errorf("fitNumberedPath(atLimit, error) error = %v, want a failure", err)
+	}
+
+	got, err = fitNumberedPath(atLimit, 12, longPathTruncate)
+	if err != nil {
+		t.Fatalf("fitNumberedPath(atLimit, truncate): %v", err)
+	}
+	if name := filepath.Base(got); len(name) != maxNameLength || !strings.HasSuffix(name, " (12).pdf") {
+		t.Errorf("fitNumberedPath(atLimit, truncate) = %q (%d bytes), want %d bytes ending in \" (12).pdf\"", name, len(name), maxNameLength)
+	}
+}
+
+func TestResolveConflictRenameLongName(t *testing.T) {
+	dir := t.TempDir()
+	name := strings.Repeat("a", maxNameLength-len(".txt")) + ".txt"
+	source := filepath.Join(dir, "dump", name)
+	dest := filepath.Join(dir, "out", name)
+	writeFile(t, source, "new", time.Time{})
+	writeFile(t, dest, "old", time.Time{})
+
+	if _, _, err := resolveConflict(onConflictRename, "", actionMove, source, dest); !errors.Is(err, errPathTooLong) {
+		t.Errorf("rename with on_long_path unset: error = %v, want errPathTooLong", err)
+	}
+
+	first, _, err := resolveConflict(onConflictRename, longPathTruncate, actionMove, source, dest)
+	if err != nil {
+		t.Fatalf("rename with truncate: %v", err)
+	}
+	if len(filepath.Base(first)) > maxNameLength || !strings.HasSuffix(first, " (1).txt") {
+		t.Errorf("rename with truncate = %q, want a name within %d bytes ending in \" (1).txt\"", filepath.Base(first), maxNameLength)
+	}
+	if err := os.WriteFile(first, nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	second, _, err := resolveConflict(onConflictRename, longPathTruncate, actionMove, source, dest)
+	if err != nil || !strings.HasSuffix(second, " (2).txt") {
+		t.Errorf("second rename with truncate = %q, %v, want one ending in \" (2).txt\"", second, err)
+	}
+}
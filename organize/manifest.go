@@ -0,0 +1,177 @@
+package organize
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// manifestEntry is one filed file in the manifest.
+type manifestEntry struct {
+	Destination string    `json:"destination"`
+	Source      string    `json:"source"`
+	Rule        string    `json:"rule"`
+	Size        int64     `json:"size"`
+	FiledAt     time.Time `json:"filed_at"`
+}
+
+var manifestHeader = []string{"destination", "source", "rule", "size", "filed_at"}
+
+// isCSVManifest reports whether the manifest at path is written as CSV
+// rather than JSON, which is decided by its extension.
+func isCSVManifest(path string) bool {
+	return strings.EqualFold(filepath.Ext(path), ".csv")
+}
+
+// ruleLabel names destination i for the manifest: its name if it has one.
+func (c *Config) ruleLabel(i int) string {
+	if c.Destinations[i].Name != "" {
+		return c.Destinations[i].Name
+	}
+	return fmt.Sprintf("destination[%d]", i)
+}
+
+// errCorruptManifest reports a manifest that can't be parsed.
+var errCorruptManifest = errors.New("manifest is corrupt")
+
+// updateManifest merges the files filed in a pass into the manifest at
+// config.ManifestPath. A file filed again to the same location replaces its
+// old entry. The manifest is rewritten through a temp file and a rename so
+// a reader never sees it half written. A manifest that can't be read is
+// left alone and not updated; one that can't be parsed is moved aside to
+// <path>.corrupt-<time> and a new one started, so no entry is lost.
+func updateManifest(config *Config, moves []FileMove) {
+	if len(moves) == 0 {
+		return
+	}
+	path := config.ManifestPath
+
+	entries, err := readManifest(path)
+	if errors.Is(err, errCorruptManifest) {
+		aside := path + ".corrupt-" + clock.Now().Format("20060102-150405")
+		if renameErr := os.Rename(path, aside); renameErr != nil {
+			log.Printf("failed to move corrupt manifest aside, not updating it: %v", renameErr)
+			return
+		}
+		log.Printf("%v; moved it to %s and starting a new one", err, aside)
+		entries = nil
+	} else if err != nil {
+		log.Printf("failed to read manifest, not updating it: %v", err)
+		return
+	}
+
+	index := make(map[string]int, len(entries))
+	for i, entry := range entries {
+		index[entry.Destination] = i
+	}
+	now := clock.Now()
+	for _, move := range moves {
+		entry := manifestEntry{
+			Destination: move.Destination,
+			Source:      move.Source,
+			Rule:        config.ruleLabel(move.Rule),
+			Size:        move.Size,
+			FiledAt:     now,
+		}
+		if i, ok := index[entry.Destination]; ok {
+			entries[i] = entry
+		} else {
+			index[entry.Destination] = len(entries)
+			entries = append(entries, entry)
+		}
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].Destination < entries[j].Destination
+	})
+
+	if err := writeManifest(path, entries); err != nil {
+		log.Printf("failed to write manifest: %v", err)
+	}
+}
+
+func readManifest(path string) ([]manifestEntry, error) {
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if !isCSVManifest(path) {
+		var entries []manifestEntry
+		if err := json.Unmarshal(data, &entries); err != nil {
+			return nil, fmt.Errorf("%w: %s: %v", errCorruptManifest, path, err)
+		}
+		return entries, nil
+	}
+
+	records, err := csv.NewReader(bytes.NewReader(data)).ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s: %v", errCorruptManifest, path, err)
+	}
+	var entries []manifestEntry
+	for i, record := range records {
+		if i == 0 || len(record) != len(manifestHeader) {
+			continue
+		}
+		size, _ := strconv.ParseInt(record[3], 10, 64)
+		filedAt, _ := time.Parse(time.RFC3339, record[4])
+		entries = append(entries, manifestEntry{
+			Destination: record[0],
+			Source:      record[1],
+			Rule:        record[2],
+			Size:        size,
+			FiledAt:     filedAt,
+		})
+	}
+	return entries, nil
+}
+
+func writeManifest(path string, entries []manifestEntry) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	file, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+
+	if isCSVManifest(path) {
+		w := csv.NewWriter(file)
+		w.Write(manifestHeader)
+		for _, entry := range entries {
+			w.Write([]string{
+				entry.Destination,
+				entry.Source,
+				entry.Rule,
+				strconv.FormatInt(entry.Size, 10),
+				entry.FiledAt.Format(time.RFC3339),
+			})
+		}
+		w.Flush()
+		err = w.Error()
+	} else {
+		encoder := json.NewEncoder(file)
+		encoder.SetIndent("", "  ")
+		err = encoder.Encode(entries)
+	}
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return os.Rename(tmp, path)
+}
@@ -0,0 +1,62 @@
+package organize
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestUpdateManifest(t *testing.T) {
+	for _, name := range []string{"manifest.json", "manifest.csv"} {
+		t.Run(name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), name)
+			config := &Config{ManifestPath: path, Destinations: []Destination{{Name: "docs"}}}
+
+			updateManifest(config, []FileMove{{Source: "/dump/a.pdf", Destination: "/out/a.pdf", Size: 1}})
+			updateManifest(config, []FileMove{
+				{Source: "/dump/b.pdf", Destination: "/out/b.pdf", Size: 2},
+				{Source: "/dump/a.pdf", Destination: "/out/a.pdf", Size: 3},
+			})
+
+			entries, err := readManifest(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(entries) != 2 || entries[0].Destination != "/out/a.pdf" || entries[0].Size != 3 || entries[1].Rule != "docs" {
+				t.Errorf("entries = %+v, want a.pdf (size 3) and b.pdf filed by docs", entries)
+			}
+		})
+	}
+}
+
+func TestUpdateManifestCorrupt(t *testing.T) {
+	useFakeClock(t, time.Date(2024, 3, 15, 9, 30, 0, 0, time.Local))
+	dir := t.TempDir()
+	path := filepath.Join(dir, "manifest.json")
+	writeFile(t, path, "{not json", time.Time{})
+
+	updateManifest(&Config{ManifestPath: path, Destinations: []Destination{{}}}, []FileMove{{Source: "/dump/a.pdf", Destination: "/out/a.pdf"}})
+
+	if got := readFile(t, filepath.Join(dir, "manifest.json.corrupt-20240315-093000")); got != "{not json" {
+		t.Errorf("corrupt manifest moved aside as %q, want the original content", got)
+	}
+	entries, err := readManifest(path)
+	if err != nil || len(entries) != 1 {
+		t.Errorf("new manifest = %+v, %v; want the one new entry", entries, err)
+	}
+}
+
+func TestUpdateManifestUnreadable(t *testing.T) {
+	// A directory in the manifest's place can't be read as one.
+	path := filepath.Join(t.TempDir(), "manifest.json")
+	if err := os.Mkdir(path, 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	updateManifest(&Config{ManifestPath: path, Destinations: []Destination{{}}}, []FileMove{{Source: "/dump/a.pdf", Destination: "/out/a.pdf"}})
+
+	if info, err := os.Stat(path); err != nil || !info.IsDir() {
+		t.Errorf("unreadable manifest was replaced: %v", err)
+	}
+}
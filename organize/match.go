@@ -0,0 +1,225 @@
+package organize
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// hasNameConditions reports whether dest declares any condition on the
+// file name.
+func hasNameConditions(dest Destination) bool {
+	return dest.Prefix != "" || dest.Suffix != "" || len(dest.Exact) > 0 || len(dest.Extensions) > 0 || dest.Pattern != "" || dest.Regex != "" || dest.FilenameDateFormat != ""
+}
+
+// hasFileConditions reports whether dest declares any condition that needs
+// to look at the file itself rather than its name.
+func hasFileConditions(dest Destination) bool {
+	return len(dest.magic) > 0 || dest.MatchOwner != "" || hasSidecarConditions(dest) || len(dest.DumpDirectories) > 0 || dest.SourceDir != "" || hasContainsConditions(dest) || hasDimensionConditions(dest)
+}
+
+// matchesFileConditions checks the conditions of dest that need the file at
+// path. Files are only opened for destinations that declare such conditions.
+func matchesFileConditions(path string, dest Destination) bool {
+	if len(dest.DumpDirectories) > 0 && !inDumpDirectory(path, dest.DumpDirectories) {
+		return false
+	}
+	if dest.SourceDir != "" && !matchesSourceDir(path, dest.SourceDir) {
+		return false
+	}
+	if dest.MatchOwner != "" && !matchesOwner(path, dest) {
+		return false
+	}
+	if len(dest.magic) > 0 && !hasMagic(path, dest.magic) {
+		return false
+	}
+	if hasSidecarConditions(dest) && !matchesSidecar(path, dest) {
+		return false
+	}
+	if hasContainsConditions(dest) && !matchesContains(path, dest) {
+		return false
+	}
+	if hasDimensionConditions(dest) && !matchesDimensions(path, dest) {
+		return false
+	}
+	return true
+}
+
+// mightMatch is a cheap name-only check of whether a file called filename
+// could be filed by any destination. Conditions on the file itself can only
+// rule out more files, so a false result is definite.
+func (c *Config) mightMatch(filename string) bool {
+	if c.ignored(filename) {
+		return false
+	}
+	_, ok := c.matchDestination(filename)
+	return ok
+}
+
+// nextMatch returns the first destination after index after (-1 to start
+// from the beginning) that the file at path fully matches.
+// A directory only matches destinations with include_directories.
+func (c *Config) nextMatch(path, filename string, after int) (int, bool) {
+	var i int
+	var ok bool
+	if after < 0 {
+		i, ok = c.matchDestination(filename)
+	} else {
+		i, ok = c.matchDestinationAfter(filename, after)
+	}
+	isDir := c.includeDirectories && isDirectory(path)
+	for ok && (isDir && !c.Destinations[i].IncludeDirectories || !matchesFileConditions(path, c.Destinations[i])) {
+		i, ok = c.matchDestinationAfter(filename, i)
+	}
+	return i, ok
+}
+
+// dumpDirs returns every directory to organize: dump_directory followed
+// by dump_directories, without repeats.
+func (c *Config) dumpDirs() []string {
+	var dirs []string
+	for _, dir := range append([]string{c.DumpDirectory}, c.DumpDirectories...) {
+		if dir != "" && !slices.Contains(dirs, filepath.Clean(dir)) {
+			dirs = append(dirs, filepath.Clean(dir))
+		}
+	}
+	return dirs
+}
+
+// inDumpDirectory reports whether the file at path is in one of dirs or,
+// in recursive mode, below it.
+func inDumpDirectory(path string, dirs []string) bool {
+	for _, dir := range dirs {
+		rel, err := filepath.Rel(filepath.Clean(dir), filepath.Dir(path))
+		if err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchesSourceDir reports whether the file at path was found in the
+// directory sourceDir: its full path if absolute, otherwise its base name.
+func matchesSourceDir(path, sourceDir string) bool {
+	dir := filepath.Dir(path)
+	if filepath.IsAbs(sourceDir) {
+		return dir == filepath.Clean(sourceDir)
+	}
+	return filepath.Base(dir) == sourceDir
+}
+
+// decodeMagic parses a magic number written as hex, allowing spaces and an
+// optional 0x prefix, e.g. "25504446" or "0x50 4B 03 04".
+func decodeMagic(s string) ([]byte, error) {
+	s = strings.ReplaceAll(s, " ", "")
+	s = strings.TrimPrefix(strings.TrimPrefix(s, "0x"), "0X")
+	magic, err := hex.DecodeString(s)
+	if err != nil {
+		return nil, fmt.Errorf("invalid magic %q: %w", s, err)
+	}
+	return magic, nil
+}
+
+// hasMagic reports whether the file at path starts with magic.
+func hasMagic(path string, magic []byte) bool {
+	file, err := os.Open(path)
+	if err != nil {
+		return false
+	}
+	defer file.Close()
+
+	head := make([]byte, len(magic))
+	if _, err := io.ReadFull(file, head); err != nil {
+		return false
+	}
+	return bytes.Equal(head, magic)
+}
+
+// route picks the destination for the file at path: the first fully matching
+// destination whose path can be expanded for the file. It returns the
+// destination's index (-1 if none) and the resolved destination directory.
+// A non-nil error with a valid index means the destination matched but its
+// path could not be built.
+func (c *Config) route(path, filename string) (int, string, error) {
+	i, ok := c.nextMatch(path, filename, -1)
+	for ok {
+		dir, err := expandPath(c.Destinations[i], c.TimeSource, path, filename)
+		if !errors.Is(err, errSkipRule) {
+			return i, c.rooted(dir), err
+		}
+		log.Printf("Skipping destination[%d] for %s: %v", i, filename, err)
+		i, ok = c.nextMatch(path, filename, i)
+	}
+	return -1, "", nil
+}
+
+// routeTarget is one destination a file is routed to.
+type routeTarget struct {
+	rule int
+	dir  string
+	err  error
+
+	// path is the full destination path when it was decided in advance,
+	// as for the entries of a plan.
+	path string
+}
+
+// destinationPath returns the full path the file at path is filed to for
+// target, after renaming, the encrypted suffix and long-path handling.
+func (c *Config) destinationPath(target routeTarget, path, filename string) (string, error) {
+	if target.err != nil {
+		return "", target.err
+	}
+	if target.path != "" {
+		return target.path, nil
+	}
+	dest := c.Destinations[target.rule]
+	name := filename
+	if dest.RenamePattern != "" {
+		var err error
+		if name, err = renamedName(dest, c.TimeSource, path, filename); err != nil {
+			return "", err
+		}
+	}
+	if dest.recipient != nil {
+		name += encryptedSuffix
+	}
+	destPath, err := safeJoin(target.dir, name)
+	if err != nil {
+		return "", err
+	}
+	return fitPathLength(destPath, c.OnLongPath)
+}
+
+// routes returns every destination the file at path goes to: just the one
+// picked by route, or with match_all every fully matching destination in
+// config order.
+func (c *Config) routes(path, filename string) []routeTarget {
+	if !c.MatchAll {
+		i, dir, err := c.route(path, filename)
+		if i < 0 {
+			return nil
+		}
+		return []routeTarget{{rule: i, dir: dir, err: err}}
+	}
+
+	var targets []routeTarget
+	i, ok := c.nextMatch(path, filename, -1)
+	for ok {
+		dir, err := expandPath(c.Destinations[i], c.TimeSource, path, filename)
+		if errors.Is(err, errSkipRule) {
+			log.Printf("Skipping destination[%d] for %s: %v", i, filename, err)
+		} else {
+			targets = append(targets, routeTarget{rule: i, dir: c.rooted(dir), err: err})
+		}
+		i, ok = c.nextMatch(path, filename, i)
+	}
+	return targets
+}
@@ -0,0 +1,260 @@
+package organize
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestMagic(t *testing.T) {
+	dir := t.TempDir()
+	config := &Config{DumpDirectory: dir, Destinations: []Destination{
+		{Suffix: ".bin", Magic: "0x50 4B 03 04", Path: filepath.Join(dir, "zips")},
+		{Suffix: ".bin", Magic: "25504446", Path: filepath.Join(dir, "pdfs")},
+	}}
+	if err := config.compile(); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name    string
+		content string
+		want    int
+	}{
+		{"zip.bin", "PK\x03\x04\x14\x00\x00\x00", 0},
+		{"pdf.bin", "%PDF-1.7\n", 1},
+		{"text.bin", "plain text", -1},
+		{"short.bin", "PK", -1},
+		{"empty.bin", "", -1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(dir, tt.name)
+			writeFile(t, path, tt.content, time.Time{})
+			if got, _, err := config.route(path, tt.name); err != nil || got != tt.want {
+				t.Errorf("route(%q) = %d, %v, want %d", tt.name, got, err, tt.want)
+			}
+		})
+	}
+
+	bad := &Config{DumpDirectory: dir, Destinations: []Destination{{Suffix: ".bin", Magic: "PK", Path: dir}}}
+	if err := bad.compile(); err == nil {
+		t.Error("compile accepted magic that isn't hex")
+	}
+}
+
+func TestStripCompressionExt(t *testing.T) {
+	dest := Destination{Suffix: ".sql", StripCompressionExt: true}
+	tests := []struct {
+		filename string
+		want     bool
+	}{
+		{"dump.sql", true},
+		{"dump.sql.gz", true},
+		{"dump.sql.bz2", true},
+		{"dump.sql.xz", true},
+		{"dump.sql.zst", true},
+		{"dump.sql.GZ", true},
+		{"dump.sql.zip", false},
+		{"dump.sql.gz.gz", false},
+		{"dump.csv.gz", false},
+		{"dump.gz", false},
+	}
+	for _, tt := range tests {
+		if got := matchesPattern(tt.filename, dest); got != tt.want {
+			t.Errorf("matchesPattern(%q) = %v, want %v", tt.filename, got, tt.want)
+		}
+	}
+	dest.StripCompressionExt = false
+	if matchesPattern("dump.sql.gz", dest) {
+		t.Error("dump.sql.gz matches .sql without strip_compression_ext")
+	}
+
+	// The compression extension is kept when the file is filed.
+	dir := t.TempDir()
+	dump := filepath.Join(dir, "dump")
+	out := filepath.Join(dir, "out")
+	writeFile(t, filepath.Join(dump, "dump.sql.zst"), "data", time.Time{})
+	config := &Config{DumpDirectory: dump, Destinations: []Destination{{Suffix: ".sql", StripCompressionExt: true, Path: out}}}
+	if err := config.compile(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := organizeWith(config, 1); err != nil {
+		t.Fatal(err)
+	}
+	if got := readFile(t, filepath.Join(out, "dump.sql.zst")); got != "data" {
+		t.Errorf("filed content = %q, want %q", got, "data")
+	}
+}
+
+func TestMatchStem(t *testing.T) {
+	tests := []struct {
+		name string
+		dest Destination
+		want bool
+	}{
+		{"last: suffix .tar", Destination{Suffix: ".tar", MatchStem: true}, true},
+		{"last: suffix archive", Destination{Suffix: "archive", MatchStem: true}, false},
+		{"all: suffix archive", Destination{Suffix: "archive", MatchStem: true, StemExtension: stemExtensionAll}, true},
+		{"all: suffix .tar", Destination{Suffix: ".tar", MatchStem: true, StemExtension: stemExtensionAll}, false},
+		{"last: prefix and suffix", Destination{Prefix: "arch", Suffix: "ive.tar", MatchStem: true, StemExtension: stemExtensionLast}, true},
+		{"without match_stem", Destination{Suffix: ".tar"}, false},
+		{"extensions use the full name", Destination{Extensions: []string{"gz"}, MatchStem: true}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := matchesPattern("archive.tar.gz", tt.dest); got != tt.want {
+				t.Errorf("matchesPattern(archive.tar.gz) = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	for _, tt := range []struct{ filename, last, all string }{
+		{"archive.tar.gz", "archive.tar", "archive"},
+		{"archive", "archive", "archive"},
+		{".bashrc", ".bashrc", ".bashrc"},
+		{".config.bak", ".config", ".config"},
+	} {
+		if got := stem(tt.filename, stemExtensionLast); got != tt.last {
+			t.Errorf("stem(%q, last) = %q, want %q", tt.filename, got, tt.last)
+		}
+		if got := stem(tt.filename, stemExtensionAll); got != tt.all {
+			t.Errorf("stem(%q, all) = %q, want %q", tt.filename, got, tt.all)
+		}
+	}
+}
+
+func TestSourceDirRoutesByDumpDirectory(t *testing.T) {
+	dir := t.TempDir()
+	work := filepath.Join(dir, "work")
+	home := filepath.Join(dir, "downloads")
+	writeFile(t, filepath.Join(work, "scan.pdf"), "work scan", time.Time{})
+	writeFile(t, filepath.Join(home, "scan.pdf"), "home scan", time.Time{})
+	config := &Config{DumpDirectory: work, DumpDirectories: []string{home}, Destinations: []Destination{
+		{Suffix: ".pdf", SourceDir: work, Path: filepath.Join(dir, "work-docs")},
+		{Suffix: ".pdf", SourceDir: "downloads", Path: filepath.Join(dir, "home-docs")},
+	}}
+	if err := config.compile(); err != nil {
+		t.Fatal(err)
+	}
+	result, err := organizeWith(config, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Moved != 2 {
+		t.Fatalf("moved %d files, want 2", result.Moved)
+	}
+	if got := readFile(t, filepath.Join(dir, "work-docs", "scan.pdf")); got != "work scan" {
+		t.Errorf("work-docs/scan.pdf = %q, want %q", got, "work scan")
+	}
+	if got := readFile(t, filepath.Join(dir, "home-docs", "scan.pdf")); got != "home scan" {
+		t.Errorf("home-docs/scan.pdf = %q, want %q", got, "home scan")
+	}
+}
+
+func TestExactAndPrefixOverlap(t *testing.T) {
+	insensitive := false
+	tests := []struct {
+		name         string
+		destinations []Destination
+		want         map[string]string
+	}{
+		{
+			name: "exact before prefix",
+			destinations: []Destination{
+				{Exact: []string{"statement.pdf"}, Path: "exact"},
+				{Prefix: "statement", Path: "prefix"},
+			},
+			want: map[string]string{"statement.pdf": "exact", "statement_old.pdf": "prefix", "Statement.pdf": ""},
+		},
+		{
+			name: "prefix before exact",
+			destinations: []Destination{
+				{Prefix: "statement", Path: "prefix"},
+				{Exact: []string{"statement.pdf"}, Path: "exact"},
+			},
+			want: map[string]string{"statement.pdf": "prefix", "statement_old.pdf": "prefix"},
+		},
+		{
+			name: "exact and prefix on one rule",
+			destinations: []Destination{
+				{Exact: []string{"statement.pdf", "summary.pdf"}, Prefix: "state", Path: "both"},
+			},
+			want: map[string]string{"statement.pdf": "both", "summary.pdf": "", "statement_old.pdf": ""},
+		},
+		{
+			name: "case-insensitive exact",
+			destinations: []Destination{
+				{Exact: []string{"statement.pdf"}, PrefixCaseSensitive: &insensitive, Path: "exact"},
+				{Prefix: "Statement", Path: "prefix"},
+			},
+			want: map[string]string{"STATEMENT.PDF": "exact", "Statement.pdf": "exact", "Statement_old.pdf": "prefix"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := &Config{DumpDirectory: t.TempDir(), Destinations: tt.destinations}
+			if err := config.compile(); err != nil {
+				t.Fatal(err)
+			}
+			for filename, want := range tt.want {
+				dest, ok := config.Classify(filename)
+				if ok != (want != "") || dest.Path != want {
+					t.Errorf("Classify(%q) = %q, %v, want %q", filename, dest.Path, ok, want)
+				}
+			}
+		})
+	}
+}
+
+func TestMixedCaseSensitivity(t *testing.T) {
+	sensitive, insensitive := true, false
+	tests := []struct {
+		name string
+		dest Destination
+		want map[string]bool
+	}{
+		{
+			name: "default is case-sensitive",
+			dest: Destination{Prefix: "INV_", Suffix: ".jpg"},
+			want: map[string]bool{"INV_1.jpg": true, "INV_1.JPG": false, "inv_1.jpg": false},
+		},
+		{
+			name: "case-insensitive suffix, case-sensitive prefix",
+			dest: Destination{Prefix: "INV_", Suffix: ".jpg", PrefixCaseSensitive: &sensitive, SuffixCaseSensitive: &insensitive},
+			want: map[string]bool{"INV_1.jpg": true, "INV_1.JPG": true, "INV_1.Jpg": true, "inv_1.jpg": false, "Inv_1.JPG": false},
+		},
+		{
+			name: "case-insensitive prefix, case-sensitive suffix",
+			dest: Destination{Prefix: "INV_", Suffix: ".jpg", PrefixCaseSensitive: &insensitive},
+			want: map[string]bool{"inv_1.jpg": true, "Inv_1.jpg": true, "INV_1.JPG": false},
+		},
+		{
+			name: "case-insensitive suffix alone",
+			dest: Destination{Suffix: ".jpg", SuffixCaseSensitive: &insensitive},
+			want: map[string]bool{"photo.JPG": true, "photo.jpg": true, "photo.jpeg": false},
+		},
+		{
+			name: "case-insensitive prefix alone",
+			dest: Destination{Prefix: "INV_", PrefixCaseSensitive: &insensitive},
+			want: map[string]bool{"inv_1.pdf": true, "INV_1.pdf": true, "IN_1.pdf": false},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dest := tt.dest
+			dest.Path = "out"
+			config := &Config{DumpDirectory: t.TempDir(), Destinations: []Destination{dest}}
+			if err := config.compile(); err != nil {
+				t.Fatal(err)
+			}
+			for filename, want := range tt.want {
+				if got := matchesPattern(filename, dest); got != want {
+					t.Errorf("matchesPattern(%q) = %v, want %v", filename, got, want)
+				}
+				if _, got := config.Classify(filename); got != want {
+					t.Errorf("Classify(%q) matched = %v, want %v", filename, got, want)
+				}
+			}
+		})
+	}
+}
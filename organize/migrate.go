@@ -0,0 +1,74 @@
+package organize
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// legacyConfigName is where older versions of prefix read their config
+// from, relative to the home directory.
+const legacyConfigName = ".prefix.yaml"
+
+// cmdMigrate moves a legacy ~/.prefix.yaml to ~/.config/prefix/prefix.yaml.
+// The legacy file is checked against the current schema first, written to
+// the new location unchanged (keeping its comments), and renamed to
+// ~/.prefix.yaml.bak. It runs before the config is loaded, since the new
+// config usually doesn't exist yet.
+func cmdMigrate(args []string) int {
+	if len(args) > 0 {
+		fmt.Fprintln(os.Stderr, "usage: prefix migrate")
+		return 2
+	}
+
+	home, err := os.UserHomeDir()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "could not get home directory: %v\n", err)
+		return 1
+	}
+	legacyPath := filepath.Join(home, legacyConfigName)
+	newPath := filepath.Join(home, ".config", "prefix", "prefix.yaml")
+
+	data, err := os.ReadFile(legacyPath)
+	if errors.Is(err, os.ErrNotExist) {
+		fmt.Printf("No legacy config at %s, nothing to migrate\n", legacyPath)
+		return 0
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to read legacy config: %v\n", err)
+		return 1
+	}
+	if _, err := os.Stat(newPath); err == nil {
+		fmt.Fprintf(os.Stderr, "%s already exists; merge %s into it by hand, or move it aside and run migrate again\n", newPath, legacyPath)
+		return 1
+	}
+
+	var config Config
+	if err := decodeStrict(data, &config); err != nil {
+		fmt.Fprintf(os.Stderr, "%s can't be migrated as is: %v\n", legacyPath, err)
+		return 1
+	}
+
+	if err := os.MkdirAll(filepath.Dir(newPath), 0o755); err != nil {
+		fmt.Fprintf(os.Stderr, "failed to create config directory: %v\n", err)
+		return 1
+	}
+	if err := os.WriteFile(newPath, data, 0o644); err != nil {
+		fmt.Fprintf(os.Stderr, "failed to write config: %v\n", err)
+		return 1
+	}
+	fmt.Printf("Wrote %s\n", newPath)
+
+	backupPath := legacyPath + ".bak"
+	if err := os.Rename(legacyPath, backupPath); err != nil {
+		fmt.Fprintf(os.Stderr, "failed to back up legacy config: %v\n", err)
+		return 1
+	}
+	fmt.Printf("Moved %s to %s\n", legacyPath, backupPath)
+
+	if problems := config.Validate(); len(problems) > 0 {
+		fmt.Printf("The migrated config has %d problem(s) to fix before starting; run \"prefix validate\"\n", len(problems))
+	}
+	return 0
+}
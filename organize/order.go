@@ -0,0 +1,58 @@
+package organize
+
+import (
+	"os"
+	"sort"
+	"time"
+)
+
+const (
+	orderName      = "name"
+	orderSizeDesc  = "size_desc"
+	orderMtimeAsc  = "mtime_asc"
+	orderMtimeDesc = "mtime_desc"
+)
+
+func validProcessOrder(order string) bool {
+	switch order {
+	case "", orderName, orderSizeDesc, orderMtimeAsc, orderMtimeDesc:
+		return true
+	}
+	return false
+}
+
+// sortCandidates orders candidates, which arrive sorted by path, for
+// process_order. The sort is stable so ties keep path order and every pass
+// sees the same sequence. Files that can't be stat'ed sort last.
+func sortCandidates(candidates []candidate, order string) {
+	if order == "" || order == orderName {
+		return
+	}
+
+	type key struct {
+		size  int64
+		mtime time.Time
+		ok    bool
+	}
+	keys := make(map[string]key, len(candidates))
+	for _, file := range candidates {
+		if info, err := os.Lstat(file.path); err == nil {
+			keys[file.path] = key{size: info.Size(), mtime: info.ModTime(), ok: true}
+		}
+	}
+
+	sort.SliceStable(candidates, func(i, j int) bool {
+		a, b := keys[candidates[i].path], keys[candidates[j].path]
+		if a.ok != b.ok {
+			return a.ok
+		}
+		switch order {
+		case orderSizeDesc:
+			return a.size > b.size
+		case orderMtimeAsc:
+			return a.mtime.Before(b.mtime)
+		default:
+			return a.mtime.After(b.mtime)
+		}
+	})
+}
@@ -0,0 +1,1784 @@
+// Package organize files the contents of dump directories into
+// destinations by name, as configured in ~/.config/prefix/prefix.yaml. It is
+// the whole of the prefix command, which is a thin wrapper around Run, and
+// can be embedded in other programs: Classify looks up a name's destination,
+// Organize runs one pass and Organizer watches for new files.
+package organize
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+	"runtime/debug"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"filippo.io/age"
+	"gopkg.in/yaml.v3"
+)
+
+type Config struct {
+	DumpDirectory string        `yaml:"dump_directory"`
+	Destinations  []Destination `yaml:"destinations"`
+	TimeSource    string        `yaml:"time_source,omitempty"`
+
+	// DumpDirectories are further directories organized like
+	// DumpDirectory, e.g. Downloads and Desktop. Either may be used alone.
+	DumpDirectories []string `yaml:"dump_directories,omitempty"`
+
+	// Profiles are named overrides selected with --profile or
+	// PREFIX_PROFILE; see applyProfile.
+	Profiles map[string]Profile `yaml:"profiles,omitempty"`
+
+	// DestinationRoot is prepended to every relative Destination.Path.
+	DestinationRoot string `yaml:"destination_root,omitempty"`
+
+	// DebounceSeconds is how long to wait after the last file event before
+	// organizing. Defaults to defaultDebounce.
+	DebounceSeconds float64 `yaml:"debounce_seconds,omitempty"`
+
+	// SkipIdentical treats an existing destination with the same content as
+	// the source as already filed: the source is removed and counted as moved.
+	// It is checked before OnConflict, which only applies to new content.
+	SkipIdentical bool `yaml:"skip_identical,omitempty"`
+
+	// OnLongPath is what to do when a destination path exceeds the platform
+	// limit: "skip" (default), "truncate" or "error".
+	OnLongPath string `yaml:"on_long_path,omitempty"`
+
+	// Workers is how many files a pass organizes concurrently (default 1).
+	// InitialWorkers overrides it for the startup pass over existing files.
+	Workers        int `yaml:"workers,omitempty"`
+	InitialWorkers int `yaml:"initial_workers,omitempty"`
+
+	// DryRun logs every file an organize pass would file, and where,
+	// without changing anything on disk. --dry-run sets it too.
+	DryRun bool `yaml:"dry_run,omitempty"`
+
+	// PersistState remembers files that matched no rule in
+	// ~/.config/prefix/state.json, so later passes and restarts skip them
+	// until they are modified or the destinations change.
+	PersistState bool `yaml:"persist_state,omitempty"`
+
+	// Recursive also organizes files in subdirectories of the dump
+	// directory. RemoveEmptyDirs then removes subdirectories left empty.
+	Recursive       bool `yaml:"recursive,omitempty"`
+	RemoveEmptyDirs bool `yaml:"remove_empty_dirs,omitempty"`
+
+	// includeDirectories is set when a destination has IncludeDirectories,
+	// making the dump directory's subdirectories candidates.
+	includeDirectories bool
+
+	// Schedule is an optional cron expression (e.g. "0 2 * * *") on which
+	// to run organize passes, in addition to reacting to file events.
+	Schedule string `yaml:"schedule,omitempty"`
+
+	// SettleSeconds, if set, runs one follow-up pass this long after a pass
+	// that moved files, to catch files recreated while it ran.
+	SettleSeconds float64 `yaml:"settle_seconds,omitempty"`
+
+	// IgnoreExtensions are extensions of files that are never organized,
+	// such as in-progress downloads. Unset, a built-in list (crdownload,
+	// part, download, tmp, ...) is used; [] ignores nothing.
+	IgnoreExtensions []string `yaml:"ignore_extensions,omitempty"`
+
+	// StableForSeconds, if set, leaves files that were modified or changed
+	// size this recently for a later pass, so downloads still being written
+	// aren't moved. In watch mode a re-check pass is scheduled for them.
+	StableForSeconds float64 `yaml:"stable_for_seconds,omitempty"`
+
+	// MatchAll files each file to every matching destination instead of
+	// only the first.
+	MatchAll bool `yaml:"match_all,omitempty"`
+
+	// LogTimestampFormat is how app.log timestamps are written: local
+	// (default), utc, or rfc3339.
+	LogTimestampFormat string `yaml:"log_timestamp_format,omitempty"`
+
+	// SidecarSuffix names the JSON metadata file some download managers
+	// write next to a file, e.g. ".meta.json" for "a.pdf.meta.json". When
+	// set, sidecars are filed along with their file and can be matched on.
+	SidecarSuffix string `yaml:"sidecar_suffix,omitempty"`
+
+	// SidecarTimeField is the sidecar field holding the RFC 3339 download
+	// time used by sidecar_min_days. Defaults to "download_time".
+	SidecarTimeField string `yaml:"sidecar_time_field,omitempty"`
+
+	// SidecarDelete deletes a moved file's sidecar instead of moving it.
+	SidecarDelete bool `yaml:"sidecar_delete,omitempty"`
+
+	// ScanCommand is run on each matched file before it is filed, with the
+	// file's path appended, e.g. ["clamdscan", "--no-summary"]. A non-zero
+	// exit quarantines the file instead.
+	ScanCommand []string `yaml:"scan_command,omitempty"`
+
+	// ScanTimeoutSeconds bounds each scan. Defaults to 60. A scan that
+	// times out leaves the file in the dump directory.
+	ScanTimeoutSeconds float64 `yaml:"scan_timeout_seconds,omitempty"`
+
+	// QuarantineDirectory receives files rejected by scan_command.
+	QuarantineDirectory string `yaml:"quarantine_directory,omitempty"`
+
+	// Seed fixes the seed for randomized behavior so runs are reproducible.
+	// Zero (the default) uses a time-based seed. --seed overrides it.
+	Seed int64 `yaml:"seed,omitempty"`
+
+	// RuleOrder lists destination names in match priority order. Listed
+	// destinations are tried first, then the rest in config order.
+	RuleOrder []string `yaml:"rule_order,omitempty"`
+
+	// ProgressThresholdMB is the file size from which copies log their
+	// progress every few seconds. Defaults to 100; negative disables it.
+	ProgressThresholdMB float64 `yaml:"progress_threshold_mb,omitempty"`
+
+	// ConfirmThreshold is how many files the startup pass may move before
+	// asking for confirmation on a terminal. Defaults to 100; negative
+	// never asks.
+	ConfirmThreshold int `yaml:"confirm_threshold,omitempty"`
+
+	// MinFreeSpace is how much space, e.g. "10GB", must stay free on a
+	// destination's filesystem. Moves and copies that would go below it
+	// are skipped. macOS, Linux and FreeBSD only.
+	MinFreeSpace string `yaml:"min_free_space,omitempty"`
+	minFreeSpace int64
+
+	// StaleDir, if set, receives files that matched no destination for
+	// StaleAfterDays, so they don't sit in the dump directory forever.
+	StaleDir       string  `yaml:"stale_dir,omitempty"`
+	StaleAfterDays float64 `yaml:"stale_after_days,omitempty"`
+
+	// HashDenylist and HashAllowlist name files of SHA-256 hashes. Matched
+	// files whose content is on the denylist are deleted instead of filed;
+	// with an allowlist, only matched files on it are filed.
+	HashDenylist  string `yaml:"hash_denylist,omitempty"`
+	HashAllowlist string `yaml:"hash_allowlist,omitempty"`
+	hashDenylist  map[string]bool
+	hashAllowlist map[string]bool
+
+	// includes are the patterns_file and hash list files the config was
+	// read from, watched like the config file itself.
+	includes []string
+
+	// CopyRateLimit caps how fast file contents are copied, in bytes per
+	// second written like "5MB", shared by all workers. Renames within a
+	// filesystem are unaffected. Unlimited when unset.
+	CopyRateLimit string `yaml:"copy_rate_limit,omitempty"`
+	copyRateLimit int64
+
+	// PreserveOwnership makes files copied across devices (and other
+	// copies) keep their owner and group, which matters when running as
+	// root. It is best-effort: failures are logged. Unix only.
+	PreserveOwnership bool `yaml:"preserve_ownership,omitempty"`
+
+	// StormEventsPerSecond, if set, is the event rate above which the
+	// watcher treats events as a storm from bulk filesystem activity and
+	// holds off organizing until the rate drops, waiting at least
+	// StormQuietSeconds (default 30) after the last event.
+	StormEventsPerSecond float64 `yaml:"storm_events_per_second,omitempty"`
+	StormQuietSeconds    float64 `yaml:"storm_quiet_seconds,omitempty"`
+
+	// ProcessOrder is the order files are handed to the workers in: name
+	// (default), size_desc, mtime_asc or mtime_desc.
+	ProcessOrder string `yaml:"process_order,omitempty"`
+
+	// ManifestPath, if set, is a file listing where every file was filed
+	// and by which rule, updated after each pass. It is written as CSV if
+	// it ends in .csv, otherwise as JSON.
+	ManifestPath string `yaml:"manifest_path,omitempty"`
+
+	// SummaryIntervalMinutes, if set, logs cumulative stats this often in
+	// watch mode, as a heartbeat showing the process is healthy.
+	SummaryIntervalMinutes float64 `yaml:"summary_interval_minutes,omitempty"`
+
+	// ShutdownTimeoutSeconds is how long shutdown waits for an organize
+	// pass that is still running, e.g. copying a large file. Defaults to 60.
+	ShutdownTimeoutSeconds float64 `yaml:"shutdown_timeout_seconds,omitempty"`
+
+	// FlushOnShutdown runs a pending debounced organize before exiting
+	// instead of discarding it.
+	FlushOnShutdown bool `yaml:"flush_on_shutdown,omitempty"`
+
+	// SocketPath, if set, is a Unix socket streaming newline-delimited JSON
+	// events as files are organized.
+	SocketPath string `yaml:"socket_path,omitempty"`
+
+	// WebhookURL receives a JSON POST summarizing each organize pass.
+	WebhookURL string `yaml:"webhook_url,omitempty"`
+
+	index *destinationIndex
+}
+
+type Destination struct {
+	// Name identifies the destination in rule_order. A destination
+	// expanded from routes or a patterns_file is named after its block, as
+	// "<name>.<pattern>", and keeps the block's name as its group.
+	Name  string `yaml:"name,omitempty"`
+	group string
+
+	Path   string `yaml:"path"`
+	Prefix string `yaml:"prefix,omitempty"`
+	Suffix string `yaml:"suffix,omitempty"`
+
+	// Exact lists whole file names to match, e.g. "statement.pdf" but not
+	// "statement_old.pdf". Combined with Prefix or Suffix, both must match.
+	// It follows PrefixCaseSensitive.
+	Exact []string `yaml:"exact,omitempty"`
+
+	// Extensions matches the file extension, ignoring case, e.g.
+	// [pdf, docx]. Entries may have a leading dot and several parts, such
+	// as "tar.gz". Combined with Prefix or Suffix, both must match.
+	Extensions []string `yaml:"extensions,omitempty"`
+
+	// Pattern matches the full name against a glob such as
+	// "IMG_*.{jpg,png}", with **, ? and character classes. Combined with
+	// Prefix or Suffix, all must match. It follows PrefixCaseSensitive.
+	Pattern string `yaml:"pattern,omitempty"`
+	pattern *regexp.Regexp
+
+	// Regex matches the full name against a regular expression. Path can
+	// reference its named groups, so with `(?P<project>[a-z]+)-report\.pdf`
+	// a path of "/home/me/Docs/{project}/reports" files by project. A group that
+	// didn't take part in the match is handled as TokenMissing says.
+	Regex string `yaml:"regex,omitempty"`
+	regex *regexp.Regexp
+
+	// FilenameDateFormat requires the name to contain a date in this
+	// strftime-style format, e.g. "%Y-%m-%d". FilenameBefore and
+	// FilenameAfter bound that date, written "2024-01-31" or as days ago
+	// ("30d").
+	FilenameDateFormat string `yaml:"filename_date_format,omitempty"`
+	FilenameBefore     string `yaml:"filename_before,omitempty"`
+	FilenameAfter      string `yaml:"filename_after,omitempty"`
+	filenameDate       *regexp.Regexp
+	filenameLayout     string
+	filenameBefore     dateBound
+	filenameAfter      dateBound
+
+	// Routes maps prefixes to subfolders of Path, e.g. {IMG_: Photos,
+	// VID_: Videos}. At load the destination is expanded into one
+	// destination per route, in the order written.
+	Routes routeMap `yaml:"routes,omitempty"`
+
+	// PatternsFile names a file of prefix/suffix patterns, one per line.
+	// At load the destination is expanded into one destination per
+	// pattern. Relative paths are resolved against the config directory.
+	PatternsFile string `yaml:"patterns_file,omitempty"`
+
+	// PrefixCaseSensitive and SuffixCaseSensitive default to true.
+	PrefixCaseSensitive *bool `yaml:"prefix_case_sensitive,omitempty"`
+	SuffixCaseSensitive *bool `yaml:"suffix_case_sensitive,omitempty"`
+
+	// StripCompressionExt lets Suffix match past a trailing .gz, .bz2, .xz
+	// or .zst. The file keeps its full name when moved.
+	StripCompressionExt bool `yaml:"strip_compression_ext,omitempty"`
+
+	// MatchStem applies Prefix and Suffix to the name without its extension.
+	// StemExtension defines the extension: "last" (default) or "all" dots.
+	MatchStem     bool   `yaml:"match_stem,omitempty"`
+	StemExtension string `yaml:"stem_extension,omitempty"`
+
+	// DebounceSeconds overrides Config.DebounceSeconds for events on files
+	// routed to this destination.
+	DebounceSeconds float64 `yaml:"debounce_seconds,omitempty"`
+
+	// Delimiter splits the filename (without extension) into segments that
+	// Path can reference as {1}, {2}, ... TokenMissing decides what happens
+	// when a referenced segment doesn't exist: "skip" (default) falls through
+	// to the next matching destination, "error" fails the file.
+	Delimiter    string `yaml:"delimiter,omitempty"`
+	TokenMissing string `yaml:"token_missing,omitempty"`
+
+	// Magic is a hex-encoded byte sequence the file's content must start
+	// with, e.g. "25504446" (%PDF). It is decoded into magic at load.
+	Magic string `yaml:"magic,omitempty"`
+	magic []byte
+
+	// Contains requires the file's text to include this string, and
+	// ContainsRegex to match this regular expression. Only the first
+	// ContainsMaxBytes (default 1 MiB) are read, and binary files never
+	// match. ContainsCaseSensitive defaults to true.
+	Contains              string `yaml:"contains,omitempty"`
+	ContainsRegex         string `yaml:"contains_regex,omitempty"`
+	ContainsMaxBytes      int64  `yaml:"contains_max_bytes,omitempty"`
+	ContainsCaseSensitive *bool  `yaml:"contains_case_sensitive,omitempty"`
+	containsRegex         *regexp.Regexp
+
+	// MinWidth, MinHeight, MaxWidth and MaxHeight bound the pixel size of
+	// images (PNG, JPEG or GIF), read from the file's header. Other files
+	// never match a destination that sets them.
+	MinWidth  int `yaml:"min_width,omitempty"`
+	MinHeight int `yaml:"min_height,omitempty"`
+	MaxWidth  int `yaml:"max_width,omitempty"`
+	MaxHeight int `yaml:"max_height,omitempty"`
+
+	// MatchOwner requires the file to be owned by a user and/or group,
+	// written "user", ":group" or "user:group". Unix only.
+	MatchOwner string `yaml:"match_owner,omitempty"`
+	ownerUID   int
+	ownerGID   int
+
+	// DumpDirectories limits the destination to files found in these dump
+	// directories (or their subdirectories in recursive mode), so each
+	// dump directory can have its own rules.
+	DumpDirectories []string `yaml:"dump_directories,omitempty"`
+
+	// SourceDir requires the file to have been found in this directory:
+	// a full path, or a base name such as "Downloads" to match any
+	// directory of that name (e.g. a subdirectory in recursive mode).
+	SourceDir string `yaml:"source_dir,omitempty"`
+
+	// Sidecar requires fields of the file's sidecar (see sidecar_suffix)
+	// to have the given values, compared case-insensitively. URL fields can
+	// be matched on their host as "<field>.host", e.g. "url.host".
+	Sidecar map[string]string `yaml:"sidecar,omitempty"`
+
+	// SidecarMinDays requires the sidecar's download time to be at least
+	// this many days ago.
+	SidecarMinDays   float64 `yaml:"sidecar_min_days,omitempty"`
+	sidecarSuffix    string
+	sidecarTimeField string
+
+	// RenamePattern renames files as they are filed, e.g.
+	// "{stem}-%Y-%m-%d{ext}". strftime-style conversions come from the
+	// file's timestamp; {name}, {stem} and {ext} from its original name.
+	RenamePattern string `yaml:"rename_pattern,omitempty"`
+
+	// KeepNewestMatching keeps only the most recently modified file matching
+	// this destination in its directory. After each file is filed, older
+	// versions this rule filed there are moved to ~/.config/prefix/trash.
+	KeepNewestMatching bool `yaml:"keep_newest_matching,omitempty"`
+
+	// HashSource is what the {{hash2}} path token hashes: the file's
+	// content (default) or its name.
+	HashSource string `yaml:"hash_source,omitempty"`
+
+	// Tags are applied to each file after it is filed: as Finder tags on
+	// macOS, in the user.xdg.tags attribute on Linux.
+	Tags []string `yaml:"tags,omitempty"`
+
+	// Pipeline is run on each file after it is filed, e.g. to make a
+	// thumbnail or run OCR. A failing step stops the pipeline and is
+	// logged; with PipelineUndoOnFailure the filing is also undone.
+	Pipeline              []PipelineStep `yaml:"pipeline,omitempty"`
+	PipelineUndoOnFailure bool           `yaml:"pipeline_undo_on_failure,omitempty"`
+
+	// Encrypt is an age public key ("age1..."). Files are encrypted to it
+	// as they are filed, under their name plus ".age"; the plaintext is
+	// never written to the destination. Only the move and copy actions
+	// can encrypt.
+	Encrypt   string `yaml:"encrypt,omitempty"`
+	recipient age.Recipient
+
+	// Action is how matched files are filed: move (default), copy, symlink
+	// or hardlink.
+	Action string `yaml:"action,omitempty"`
+
+	// IncludeDirectories also matches subdirectories of the dump directory
+	// by name and moves each matching one whole. Other destinations only
+	// match files.
+	IncludeDirectories bool `yaml:"include_directories,omitempty"`
+
+	// OnConflict decides what happens when the destination file already
+	// exists: skip, overwrite, rename (to "name (1).ext", ...) or newer
+	// (overwrite only with a newer source). Unset, the file fails with an
+	// error and stays in the dump directory.
+	OnConflict string `yaml:"on_conflict,omitempty"`
+}
+
+// LoadConfig reads ~/.config/prefix/prefix.yaml and applies the named
+// profile, if any. When the file is missing and createIfMissing is set, a
+// template is written for the user to fill in.
+func LoadConfig(createIfMissing bool, profile string) (*Config, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		log.Printf("could not get home directory: %v\n", err)
+		return nil, fmt.Errorf("could not get home directory: %w", err)
+	}
+
+	configFileName := filepath.Join(home, ".config", "prefix", "prefix.yaml")
+	file, err := os.Open(configFileName)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			log.Printf("File not found: %s\n", configFileName)
+			if !createIfMissing {
+				return nil, fmt.Errorf("config file not found: %s", configFileName)
+			}
+			log.Printf("Creating a new config file, add the dump and destinations")
+			// Handle file not existing (e.g., create it, exit)
+			newConfigFile, err := os.Create(configFileName)
+			if err != nil {
+				log.Fatalf("Error create new config file %e:", err)
+			}
+			defer newConfigFile.Close()
+
+			// Write default config template
+			defaultConfig := `dump_directory: ""
+
+destinations:
+  - path: ""
+    prefix: ""
+    # suffix: ""
+`
+			if _, err := newConfigFile.WriteString(defaultConfig); err != nil {
+				log.Fatalf("Error writing default config: %v", err)
+			}
+			log.Printf("Created default config file at %s. Please edit it and restart the program.", configFileName)
+			return nil, fmt.Errorf("config file created, please configure it")
+		} else {
+			log.Fatalf("Error opening file: %v\n", err)
+		}
+	}
+	defer file.Close()
+
+	log.Printf("File exists and opened successfully: %s\n", configFileName)
+
+	return loadConfigFile(configFileName, profile)
+}
+
+// loadConfigFile parses the config at path, applies the named profile and
+// merges the rule files from the rules.d directory next to it.
+func loadConfigFile(path, profile string) (*Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		log.Printf("failed to read config file: %v", err)
+		return nil, fmt.Errorf("failed to read config file: %w", err)
+	}
+
+	var config Config
+	if err := decodeStrict(data, &config); err != nil {
+		log.Printf("failed to parse YAML: %v", err)
+		return nil, fmt.Errorf("failed to parse YAML: %w", err)
+	}
+
+	if err := config.applyProfile(profile); err != nil {
+		log.Printf("failed to apply profile: %v", err)
+		return nil, err
+	}
+	if err := config.applyEnv(); err != nil {
+		log.Printf("failed to apply environment overrides: %v", err)
+		return nil, err
+	}
+
+	rules, err := loadRuleFiles(filepath.Join(filepath.Dir(path), "rules.d"))
+	if err != nil {
+		return nil, err
+	}
+	config.Destinations = append(config.Destinations, rules...)
+	err = config.expandPatternsFiles(filepath.Dir(path))
+	if err == nil {
+		err = config.loadHashLists(filepath.Dir(path))
+	}
+	if err == nil {
+		err = config.expandRoutes()
+	}
+	if err == nil {
+		err = config.applyRuleOrder()
+	}
+	if err == nil {
+		err = config.compile()
+	}
+	if err != nil {
+		// Report the remaining problems too rather than one at a time.
+		err = errors.Join(append(splitErrors(err), config.Validate()...)...)
+		log.Printf("invalid config: %v", err)
+		return nil, err
+	}
+
+	return &config, nil
+}
+
+// compile prepares the destinations for matching: it decodes per-destination
+// settings that are parsed once at load and (re)builds the lookup index. It
+// must be called whenever Destinations changes.
+func (c *Config) compile() error {
+	var errs []error
+	if c.CopyRateLimit != "" {
+		rate, err := parseSize(c.CopyRateLimit)
+		if err == nil && rate <= 0 {
+			err = fmt.Errorf("must be positive")
+		}
+		if err != nil {
+			errs = append(errs, fmt.Errorf("copy_rate_limit: %w", err))
+		}
+		c.copyRateLimit = rate
+	}
+	if c.MinFreeSpace != "" {
+		size, err := parseSize(c.MinFreeSpace)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("min_free_space: %w", err))
+		}
+		c.minFreeSpace = size
+	}
+	for i := range c.Destinations {
+		dest := &c.Destinations[i]
+		if dest.Magic != "" {
+			magic, err := decodeMagic(dest.Magic)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("destination[%d]: %w", i, err))
+			}
+			dest.magic = magic
+		}
+		if dest.Encrypt != "" {
+			recipient, err := age.ParseX25519Recipient(dest.Encrypt)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("destination[%d]: invalid encrypt key: %w", i, err))
+			} else {
+				dest.recipient = recipient
+			}
+		}
+		if dest.Pattern != "" {
+			re, err := compileGlob(dest.Pattern, isCaseSensitive(dest.PrefixCaseSensitive))
+			if err != nil {
+				errs = append(errs, fmt.Errorf("destination[%d]: %w", i, err))
+			}
+			dest.pattern = re
+		}
+		if dest.Regex != "" {
+			re, err := regexp.Compile(dest.Regex)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("destination[%d]: invalid regex: %w", i, err))
+			}
+			dest.regex = re
+		}
+		if dest.FilenameDateFormat != "" {
+			re, layout, err := compileFilenameDate(dest.FilenameDateFormat)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("destination[%d]: %w", i, err))
+			}
+			dest.filenameDate, dest.filenameLayout = re, layout
+		}
+		for _, bound := range []struct {
+			option string
+			value  string
+			target *dateBound
+		}{
+			{"filename_before", dest.FilenameBefore, &dest.filenameBefore},
+			{"filename_after", dest.FilenameAfter, &dest.filenameAfter},
+		} {
+			if bound.value == "" {
+				continue
+			}
+			if dest.FilenameDateFormat == "" {
+				errs = append(errs, fmt.Errorf("destination[%d]: %s needs filename_date_format", i, bound.option))
+				continue
+			}
+			b, err := parseDateBound(bound.value)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("destination[%d]: %s: %w", i, bound.option, err))
+			}
+			*bound.target = b
+		}
+		if dest.ContainsRegex != "" {
+			re, err := regexp.Compile(dest.ContainsRegex)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("destination[%d]: invalid contains_regex: %w", i, err))
+			}
+			dest.containsRegex = re
+		}
+		if dest.MatchOwner != "" {
+			if !ownerSupported {
+				errs = append(errs, fmt.Errorf("destination[%d]: match_owner is not supported on this platform", i))
+			} else if uid, gid, err := resolveOwner(dest.MatchOwner); err != nil {
+				errs = append(errs, fmt.Errorf("destination[%d]: %w", i, err))
+			} else {
+				dest.ownerUID, dest.ownerGID = uid, gid
+			}
+		}
+		if hasSidecarConditions(*dest) {
+			if c.SidecarSuffix == "" {
+				errs = append(errs, fmt.Errorf("destination[%d]: sidecar conditions need sidecar_suffix", i))
+			}
+			dest.sidecarSuffix = c.SidecarSuffix
+			dest.sidecarTimeField = c.SidecarTimeField
+			if dest.sidecarTimeField == "" {
+				dest.sidecarTimeField = defaultSidecarTimeField
+			}
+		}
+	}
+
+	if len(errs) > 0 {
+		return errors.Join(errs...)
+	}
+
+	c.includeDirectories = slices.ContainsFunc(c.Destinations, func(dest Destination) bool { return dest.IncludeDirectories })
+	c.buildIndex()
+	return nil
+}
+
+// decodeStrict decodes YAML into v, rejecting fields v doesn't have and
+// duplicate keys, so a typo or a pasted block that wasn't fully edited fails
+// loudly instead of being ignored or silently overriding an earlier value.
+// An empty document leaves v unchanged.
+func decodeStrict(data []byte, v any) error {
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(v); err != nil && !errors.Is(err, io.EOF) {
+		return err
+	}
+	return nil
+}
+
+// isYAMLSequence reports whether the YAML document in data is a list.
+func isYAMLSequence(data []byte) bool {
+	var node yaml.Node
+	if err := yaml.Unmarshal(data, &node); err != nil || len(node.Content) == 0 {
+		return false
+	}
+	return node.Content[0].Kind == yaml.SequenceNode
+}
+
+// loadRuleFiles reads every *.yaml file in dir, in sorted filename order, and
+// returns the destinations they declare. Each file holds either a single
+// destination or a list of them. A missing dir is not an error.
+func loadRuleFiles(dir string) ([]Destination, error) {
+	ruleFiles, err := filepath.Glob(filepath.Join(dir, "*.yaml"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list rule files: %w", err)
+	}
+	sort.Strings(ruleFiles)
+
+	var destinations []Destination
+	for _, ruleFile := range ruleFiles {
+		data, err := os.ReadFile(ruleFile)
+		if err != nil {
+			log.Printf("failed to read rule file: %v", err)
+			return nil, fmt.Errorf("failed to read rule file: %w", err)
+		}
+
+		var list []Destination
+		if isYAMLSequence(data) {
+			err = decodeStrict(data, &list)
+		} else {
+			var single Destination
+			err = decodeStrict(data, &single)
+			list = []Destination{single}
+		}
+		if err != nil {
+			log.Printf("failed to parse rule file %s: %v", ruleFile, err)
+			return nil, fmt.Errorf("failed to parse rule file %s: %w", ruleFile, err)
+		}
+
+		log.Printf("Loaded %d rule(s) from %s", len(list), ruleFile)
+		destinations = append(destinations, list...)
+	}
+
+	return destinations, nil
+}
+
+func matchesPattern(filename string, dest Destination) bool {
+	// exact, extensions, pattern, regex and filename dates always apply to
+	// the full name, even with match_stem
+	if len(dest.Exact) > 0 && !matchesExact(filename, dest) {
+		return false
+	}
+	if len(dest.Extensions) > 0 && !matchesExtension(filename, dest.Extensions) {
+		return false
+	}
+	if dest.pattern != nil && !dest.pattern.MatchString(filename) {
+		return false
+	}
+	if dest.regex != nil && !dest.regex.MatchString(filename) {
+		return false
+	}
+	if dest.filenameDate != nil && !matchesFilenameDate(filename, dest) {
+		return false
+	}
+	if dest.MatchStem {
+		filename = stem(filename, dest.StemExtension)
+	}
+
+	prefixCase := isCaseSensitive(dest.PrefixCaseSensitive)
+	suffixCase := isCaseSensitive(dest.SuffixCaseSensitive)
+
+	// when both prefix and suffix are specified, both must match
+	if dest.Prefix != "" && dest.Suffix != "" {
+		return hasPrefix(filename, dest.Prefix, prefixCase) && matchesSuffix(filename, dest, suffixCase)
+	}
+	if dest.Prefix != "" {
+		return hasPrefix(filename, dest.Prefix, prefixCase)
+	}
+	if dest.Suffix != "" {
+		return matchesSuffix(filename, dest, suffixCase)
+	}
+	// with no name conditions, the destination matches on file conditions alone
+	return len(dest.Exact) > 0 || len(dest.Extensions) > 0 || dest.pattern != nil || dest.regex != nil || dest.filenameDate != nil || hasFileConditions(dest)
+}
+
+// matchesExact reports whether filename is one of dest.Exact, compared
+// according to prefix_case_sensitive.
+func matchesExact(filename string, dest Destination) bool {
+	caseSensitive := isCaseSensitive(dest.PrefixCaseSensitive)
+	for _, name := range dest.Exact {
+		if name == filename || (!caseSensitive && strings.EqualFold(name, filename)) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchesExtension reports whether filename ends in one of extensions,
+// ignoring case. A name that is only the extension, like ".pdf", doesn't
+// count.
+func matchesExtension(filename string, extensions []string) bool {
+	for _, ext := range extensions {
+		ext = "." + strings.TrimPrefix(ext, ".")
+		if len(filename) > len(ext) && hasSuffix(filename, ext, false) {
+			return true
+		}
+	}
+	return false
+}
+
+const (
+	stemExtensionLast = "last"
+	stemExtensionAll  = "all"
+)
+
+// stem strips the extension from filename. With "last" (the default) only
+// the final ".ext" is removed, so "archive.tar.gz" becomes "archive.tar";
+// with "all" everything from the first dot is, giving "archive". A leading
+// dot (hidden files) is not treated as an extension.
+func stem(filename, extension string) string {
+	if extension == stemExtensionAll && filename != "" {
+		if i := strings.Index(filename[1:], "."); i >= 0 {
+			return filename[:i+1]
+		}
+		return filename
+	}
+	ext := filepath.Ext(filename)
+	if ext == filename {
+		return filename
+	}
+	return strings.TrimSuffix(filename, ext)
+}
+
+// compressionExts are the extensions strip_compression_ext looks past.
+var compressionExts = []string{".gz", ".bz2", ".xz", ".zst"}
+
+// matchesSuffix checks dest.Suffix against filename and, with
+// strip_compression_ext, against filename minus a trailing compression
+// extension, so ".sql" also matches "dump.sql.gz".
+func matchesSuffix(filename string, dest Destination, caseSensitive bool) bool {
+	if hasSuffix(filename, dest.Suffix, caseSensitive) {
+		return true
+	}
+	if !dest.StripCompressionExt {
+		return false
+	}
+	for _, ext := range compressionExts {
+		if hasSuffix(filename, ext, false) {
+			return hasSuffix(filename[:len(filename)-len(ext)], dest.Suffix, caseSensitive)
+		}
+	}
+	return false
+}
+
+// isCaseSensitive resolves an optional case-sensitivity setting, which
+// defaults to case-sensitive.
+func isCaseSensitive(setting *bool) bool {
+	return setting == nil || *setting
+}
+
+func hasPrefix(s, prefix string, caseSensitive bool) bool {
+	if caseSensitive {
+		return strings.HasPrefix(s, prefix)
+	}
+	return len(s) >= len(prefix) && strings.EqualFold(s[:len(prefix)], prefix)
+}
+
+func hasSuffix(s, suffix string, caseSensitive bool) bool {
+	if caseSensitive {
+		return strings.HasSuffix(s, suffix)
+	}
+	return len(s) >= len(suffix) && strings.EqualFold(s[len(s)-len(suffix):], suffix)
+}
+
+var errDestinationExists = errors.New("destination file already exists")
+
+// errSourceGone reports that the source was deleted or moved away by
+// someone else after the dump directory was read.
+var errSourceGone = errors.New("source file no longer exists")
+
+func sourceMissing(path string) bool {
+	_, err := os.Lstat(path)
+	return errors.Is(err, os.ErrNotExist)
+}
+
+// forceOverwrite makes moveFile back up and replace existing destinations.
+// It is only set for the startup pass when running with --force.
+var forceOverwrite atomic.Bool
+
+// backupExisting renames the file at path out of the way to path.bak, or
+// path.bak.1, path.bak.2, ... if earlier backups exist.
+func backupExisting(path string) error {
+	backup := path + ".bak"
+	for n := 1; ; n++ {
+		if _, err := os.Lstat(backup); os.IsNotExist(err) {
+			break
+		}
+		backup = fmt.Sprintf("%s.bak.%d", path, n)
+	}
+
+	if err := os.Rename(path, backup); err != nil {
+		log.Printf("failed to back up existing destination: %v", err)
+		return fmt.Errorf("failed to back up existing destination: %w", err)
+	}
+	log.Printf("Backed up existing destination: %s -> %s", path, backup)
+	return nil
+}
+
+// initialWorkers returns the worker count for the startup pass.
+func (c *Config) initialWorkers() int {
+	if c.InitialWorkers > 0 {
+		return c.InitialWorkers
+	}
+	return c.Workers
+}
+
+// rooted prefixes a relative destination directory with destination_root.
+// Absolute directories are returned unchanged.
+func (c *Config) rooted(dir string) string {
+	if c.DestinationRoot == "" || filepath.IsAbs(dir) {
+		return dir
+	}
+	return filepath.Join(c.DestinationRoot, dir)
+}
+
+// safeJoin joins a file's base name onto dir and rejects the result if it
+// would land outside dir, so names containing separators or ".." sequences
+// can't escape the destination.
+func safeJoin(dir, name string) (string, error) {
+	base := filepath.Base(name)
+	if base == "." || base == ".." || base == string(filepath.Separator) {
+		return "", fmt.Errorf("unsafe file name: %q", name)
+	}
+
+	joined := filepath.Join(dir, base)
+	rel, err := filepath.Rel(dir, joined)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) || filepath.IsAbs(rel) {
+		return "", fmt.Errorf("file name %q escapes destination %s", name, dir)
+	}
+	return joined, nil
+}
+
+// rename is os.Rename for moves, replaced in tests to simulate a move
+// across devices.
+var rename = os.Rename
+
+func moveFile(sourcePath, destPath string) error {
+	err := prepareDestination(destPath)
+	if errors.Is(err, errDestinationExists) && forceOverwrite.Load() {
+		err = backupExisting(destPath)
+	}
+	if err != nil {
+		return err
+	}
+
+	if err := rename(sourcePath, destPath); err == nil {
+		return nil
+	} else if sourceMissing(sourcePath) {
+		return fmt.Errorf("%w: %s", errSourceGone, sourcePath)
+	}
+
+	// The rename fails across devices; fall back to copy and remove.
+	if isDirectory(sourcePath) {
+		if err := copyDir(sourcePath, destPath); err != nil {
+			log.Printf("failed to copy directory: %v", err)
+			return fmt.Errorf("failed to copy directory: %w", err)
+		}
+		if err := os.RemoveAll(sourcePath); err != nil {
+			log.Printf("failed to remove source directory: %v", err)
+			return fmt.Errorf("failed to remove source directory: %w", err)
+		}
+		return nil
+	}
+
+	if err := copyFile(sourcePath, destPath); err != nil {
+		log.Printf("failed to copy file: %v", err)
+		return fmt.Errorf("failed to copy file: %w", err)
+	}
+
+	if err := os.Remove(sourcePath); err != nil {
+		log.Printf("failed to remove source file: %v", err)
+		return fmt.Errorf("failed to remove source file: %w", err)
+	}
+
+	return nil
+}
+
+// preserveOwnership makes copies keep the source's owner and group, from
+// the preserve_ownership option.
+var preserveOwnership atomic.Bool
+
+// tempSuffix marks the partial file copyFile writes before renaming it into
+// place, so a crash mid-copy never leaves a truncated file under the real
+// name. Leftovers are removed by the cleanup command.
+const tempSuffix = ".prefix-tmp"
+
+func copyFile(sourcePath, destPath string) error {
+	sourceFile, err := os.Open(sourcePath)
+	if err != nil {
+		log.Printf("failed to open source file: %v", err)
+		return fmt.Errorf("failed to open source file: %w", err)
+	}
+	defer func() {
+		if closeErr := sourceFile.Close(); closeErr != nil {
+			log.Printf("failed to close source file: %v", closeErr)
+		}
+	}()
+
+	tempPath := destPath + tempSuffix
+	destFile, err := os.OpenFile(tempPath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0o600)
+	if err != nil {
+		log.Printf("failed to create destination file: %v", err)
+		return fmt.Errorf("failed to create destination file: %w", err)
+	}
+	fail := func(err error) error {
+		destFile.Close()
+		if removeErr := os.Remove(tempPath); removeErr != nil {
+			log.Printf("failed to remove partial copy: %v", removeErr)
+		}
+		return err
+	}
+
+	sourceInfo, err := sourceFile.Stat()
+	if err != nil {
+		log.Printf("failed to stat source file: %v", err)
+		return fail(fmt.Errorf("failed to stat source file: %w", err))
+	}
+
+	if _, err := io.Copy(destFile, withRateLimit(withProgress(sourceFile, sourcePath, sourceInfo.Size()))); err != nil {
+		return fail(fmt.Errorf("failed to copy file content: %w", err))
+	}
+
+	// Ownership goes first: changing it can clear setuid/setgid bits.
+	if preserveOwnership.Load() {
+		if uid, gid, ok := infoOwner(sourceInfo); ok {
+			if err := destFile.Chown(uid, gid); err != nil {
+				log.Printf("Warning: could not preserve ownership of %s: %v", destPath, err)
+			}
+		}
+	}
+
+	// Copy file permissions
+	if err := destFile.Chmod(sourceInfo.Mode()); err != nil {
+		return fail(fmt.Errorf("failed to set permissions: %w", err))
+	}
+
+	if err := destFile.Close(); err != nil {
+		log.Printf("failed to close destination file: %v", err)
+		return fail(fmt.Errorf("failed to close destination file: %w", err))
+	}
+	if err := os.Rename(tempPath, destPath); err != nil {
+		return fail(fmt.Errorf("failed to rename copy into place: %w", err))
+	}
+	return nil
+}
+
+// OrganizeResult summarizes a single organize pass.
+type OrganizeResult struct {
+	Moved   int `json:"moved"`
+	Skipped int `json:"skipped"`
+
+	// Vanished counts files that disappeared before they could be filed.
+	Vanished int `json:"vanished"`
+
+	// Unstable counts files left for a later pass by stable_for_seconds.
+	Unstable int `json:"unstable"`
+
+	// Errors counts files that failed to be filed.
+	Errors int `json:"errors"`
+
+	// Deleted counts files deleted because they are on hash_denylist.
+	Deleted int `json:"deleted"`
+
+	// InsufficientSpace counts files not filed because of min_free_space.
+	InsufficientSpace int `json:"insufficient_space"`
+
+	// BytesMoved is the total size of the files filed in the pass.
+	BytesMoved int64 `json:"bytes_moved"`
+
+	// DryRun is set when nothing was filed and Moved counts the files
+	// that would have been.
+	DryRun bool `json:"dry_run,omitempty"`
+
+	// RuleMatches counts the files matched by each destination, indexed
+	// like Config.Destinations.
+	RuleMatches []int `json:"rule_matches"`
+
+	Moves []FileMove `json:"moves"`
+
+	// DurationSeconds is how long the pass took.
+	DurationSeconds float64 `json:"duration_seconds"`
+}
+
+// FileMove records one file filed during a pass.
+type FileMove struct {
+	Source      string `json:"source"`
+	Destination string `json:"destination"`
+	Size        int64  `json:"size"`
+
+	// Rule is the index of the destination the file was filed by.
+	Rule int `json:"rule"`
+}
+
+// candidate is a file in the dump directory considered for organizing.
+type candidate struct {
+	path string
+	name string
+}
+
+// collectCandidates lists the files to organize in dir, and with
+// includeDirs its subdirectories, sorted by path so every pass processes
+// them in the same order regardless of filesystem.
+func collectCandidates(dirs []string, recursive, includeDirs bool) ([]candidate, error) {
+	var candidates []candidate
+	for _, dir := range dirs {
+		if err := collectDir(dir, recursive, includeDirs, &candidates); err != nil {
+			log.Printf("failed to read dump directory: %v", err)
+			return nil, fmt.Errorf("failed to read dump directory: %w", err)
+		}
+	}
+
+	sort.Slice(candidates, func(i, j int) bool {
+		return candidates[i].path < candidates[j].path
+	})
+	return candidates, nil
+}
+
+func organizeFiles(config *Config) (*OrganizeResult, error) {
+	return organizeWith(config, config.Workers)
+}
+
+// Organize runs one organize pass over the dump directories, filing every
+// file a rule matches, and returns what it did.
+func Organize(config *Config) (*OrganizeResult, error) {
+	applySettings(config)
+	return organizeFiles(config)
+}
+
+// organizeWith runs one organize pass spreading the files over the given
+// number of workers.
+func organizeWith(config *Config, workers int) (*OrganizeResult, error) {
+	start := time.Now()
+	candidates, err := collectCandidates(config.dumpDirs(), config.Recursive, config.includeDirectories)
+	if err != nil {
+		return nil, err
+	}
+	if config.SidecarSuffix != "" {
+		// Decide up front: once a file is filed its sidecar no longer
+		// looks like one.
+		files := candidates[:0]
+		for _, file := range candidates {
+			if !config.isSidecar(file) {
+				files = append(files, file)
+			}
+		}
+		candidates = files
+	}
+	sortCandidates(candidates, config.ProcessOrder)
+
+	events.publish(Event{Type: eventPassStart})
+
+	p := &passState{
+		config: config,
+		locks:  &pathLocks{locks: make(map[string]*sync.Mutex)},
+		result: &OrganizeResult{RuleMatches: make([]int, len(config.Destinations)), DryRun: config.DryRun},
+	}
+	if config.PersistState && !config.DryRun && config.cacheable() {
+		p.state = loadScanState(config)
+		p.state.prune(candidates)
+	}
+	if config.StableForSeconds > 0 {
+		sizes.prune(candidates)
+	}
+	if config.StaleDir != "" && !config.DryRun {
+		p.stale = loadStaleTracker()
+		p.stale.prune(candidates)
+	}
+
+	if workers < 1 {
+		workers = 1
+	}
+	jobs := make(chan candidate)
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for file := range jobs {
+				p.processFileSafely(file)
+			}
+		}()
+	}
+	for _, file := range candidates {
+		jobs <- file
+	}
+	close(jobs)
+	wg.Wait()
+
+	result := p.result
+	result.DurationSeconds = time.Since(start).Seconds()
+	if !config.DryRun {
+		stats.record(result)
+	}
+	// Workers finish in any order; keep the report stable.
+	sort.Slice(result.Moves, func(i, j int) bool {
+		return result.Moves[i].Source < result.Moves[j].Source
+	})
+
+	if config.DryRun {
+		log.Printf("\nDry run summary: %d files would be filed (%s), %d files skipped", result.Moved, formatBytes(result.BytesMoved), result.Skipped)
+		return result, nil
+	}
+
+	if p.stale != nil {
+		p.stale.save()
+	}
+	if config.ManifestPath != "" {
+		updateManifest(config, result.Moves)
+	}
+	if p.state != nil {
+		p.state.save()
+		if p.unchanged > 0 {
+			log.Printf("%d previously unmatched files are unchanged and were not re-evaluated", p.unchanged)
+		}
+	}
+
+	log.Printf("\nSummary: %d files moved (%s), %d files skipped, %d files vanished", result.Moved, formatBytes(result.BytesMoved), result.Skipped, result.Vanished)
+	if result.Unstable > 0 {
+		log.Printf("%d files are still changing and were left for a later pass (stable_for_seconds)", result.Unstable)
+	}
+	if result.Deleted > 0 {
+		log.Printf("%d files were deleted because they are on hash_denylist", result.Deleted)
+	}
+	if result.InsufficientSpace > 0 {
+		log.Printf("%d files were not filed because of insufficient space (min_free_space %s)", result.InsufficientSpace, formatBytes(config.minFreeSpace))
+	}
+	events.publish(Event{Type: eventPassEnd, Moved: result.Moved, Skipped: result.Skipped})
+
+	if config.Recursive && config.RemoveEmptyDirs {
+		for _, dir := range config.dumpDirs() {
+			removeEmptyDirs(dir)
+		}
+	}
+
+	if config.WebhookURL != "" {
+		go sendWebhook(config.WebhookURL, result)
+	}
+	return result, nil
+}
+
+// passState is the bookkeeping shared by the workers of one organize pass.
+// Everything below mu is guarded by it.
+type passState struct {
+	config *Config
+	state  *scanState
+	stale  *staleTracker
+	locks  *pathLocks
+
+	mu        sync.Mutex
+	result    *OrganizeResult
+	unchanged int
+}
+
+func (p *passState) skipped() {
+	p.mu.Lock()
+	p.result.Skipped++
+	p.mu.Unlock()
+}
+
+func (p *passState) deleted() {
+	p.mu.Lock()
+	p.result.Deleted++
+	p.mu.Unlock()
+}
+
+func (p *passState) insufficientSpace() {
+	p.mu.Lock()
+	p.result.InsufficientSpace++
+	p.mu.Unlock()
+}
+
+func (p *passState) errored() {
+	p.mu.Lock()
+	p.result.Errors++
+	p.mu.Unlock()
+}
+
+func (p *passState) unstable() {
+	p.mu.Lock()
+	p.result.Unstable++
+	p.mu.Unlock()
+}
+
+func (p *passState) vanished() {
+	p.mu.Lock()
+	p.result.Vanished++
+	p.mu.Unlock()
+}
+
+func (p *passState) matched(rule int) {
+	p.mu.Lock()
+	p.result.RuleMatches[rule]++
+	p.mu.Unlock()
+}
+
+func (p *passState) moved(move FileMove) {
+	p.mu.Lock()
+	p.result.Moved++
+	p.result.BytesMoved += move.Size
+	p.result.Moves = append(p.result.Moves, move)
+	p.mu.Unlock()
+}
+
+// rejected handles a file that failed its scan: infected files are
+// quarantined, files that could not be scanned are left in place.
+func (p *passState) rejected(sourcePath string, err error) {
+	p.skipped()
+	if !errors.Is(err, errInfected) {
+		log.Printf("Not filing %s: %v", sourcePath, err)
+		events.publish(Event{Type: eventError, Source: sourcePath, Error: err.Error()})
+		p.errored()
+		return
+	}
+
+	log.Printf("Quarantining %s: %v", sourcePath, err)
+	destPath, qErr := quarantine(p.config, sourcePath)
+	if qErr != nil {
+		events.publish(Event{Type: eventError, Source: sourcePath, Error: qErr.Error()})
+		p.errored()
+		return
+	}
+	events.publish(Event{Type: eventQuarantined, Source: sourcePath, Destination: destPath})
+}
+
+// processFileSafely runs processFile, turning a panic into an error for
+// that file so the rest of the pass still runs.
+func (p *passState) processFileSafely(file candidate) {
+	defer func() {
+		if r := recover(); r != nil {
+			log.Printf("panic while organizing %s: %v\n%s", file.path, r, debug.Stack())
+			events.publish(Event{Type: eventError, Source: file.path, Error: fmt.Sprint(r)})
+			p.errored()
+		}
+	}()
+	p.processFile(file)
+}
+
+// processFile routes and files a single candidate.
+func (p *passState) processFile(file candidate) {
+	config := p.config
+	filename := file.name
+	sourcePath := file.path
+
+	if config.ignored(filename) {
+		log.Printf("Ignoring %s: in-progress download or temporary file (ignore_extensions)", filename)
+		p.skipped()
+		return
+	}
+
+	if p.state != nil && p.state.unchanged(sourcePath) {
+		p.mu.Lock()
+		p.unchanged++
+		p.mu.Unlock()
+		p.skipped()
+		p.unmatched(sourcePath)
+		return
+	}
+
+	targets := config.routes(sourcePath, filename)
+	if len(targets) == 0 {
+		log.Printf("No match found for: %s", filename)
+		events.publish(Event{Type: eventSkipped, Source: sourcePath})
+		if p.state != nil {
+			p.state.rememberUnmatched(sourcePath)
+		}
+		p.skipped()
+		p.unmatched(sourcePath)
+		return
+	}
+	p.fileTargets(file, targets)
+}
+
+// fileTargets files a candidate to the destinations it was routed to, after
+// the stability, scan and hash list checks. "prefix apply" calls it with
+// the targets of a plan.
+func (p *passState) fileTargets(file candidate, targets []routeTarget) {
+	config := p.config
+	filename := file.name
+	sourcePath := file.path
+
+	if config.StableForSeconds > 0 && !sizes.stable(sourcePath, seconds(config.StableForSeconds)) {
+		log.Printf("Not filing %s yet: it changed in the last %v", filename, seconds(config.StableForSeconds))
+		p.unstable()
+		p.skipped()
+		return
+	}
+
+	if config.DryRun {
+		p.dryRun(file, targets)
+		return
+	}
+
+	if len(config.ScanCommand) > 0 {
+		if err := scanFile(config, sourcePath); err != nil {
+			p.rejected(sourcePath, err)
+			return
+		}
+	}
+
+	if !p.checkHashLists(sourcePath) {
+		return
+	}
+
+	var size int64
+	if info, statErr := os.Lstat(sourcePath); statErr == nil && info.IsDir() {
+		size, _ = treeState(sourcePath)
+	} else if statErr == nil {
+		size = info.Size()
+	}
+
+	// With several destinations a move is done as a copy, and the source
+	// is removed once every destination has been filed.
+	fanOut := len(targets) > 1
+	removeSource := false
+	filed := 0
+	failed := false
+	seen := make(map[string]bool, len(targets))
+	for _, target := range targets {
+		p.matched(target.rule)
+		destPath, err := config.destinationPath(target, sourcePath, filename)
+		if errors.Is(err, errPathTooLong) {
+			log.Printf("Skipping %s: %v", filename, err)
+			events.publish(Event{Type: eventSkipped, Source: sourcePath})
+			failed = true
+			continue
+		}
+		if err == nil && seen[destPath] {
+			log.Printf("Skipping destination[%d] for %s: already filed to %s", target.rule, filename, destPath)
+			continue
+		}
+		seen[destPath] = true
+
+		action := config.Destinations[target.rule].Action
+		// An encrypted move keeps its plaintext until the pipeline is done:
+		// undoing it would otherwise only have the ciphertext to put back.
+		encryptedPipeline := config.Destinations[target.rule].recipient != nil && len(config.Destinations[target.rule].Pipeline) > 0
+		if (fanOut || encryptedPipeline) && actionName(action) == actionMove {
+			action = actionCopy
+			removeSource = true
+		}
+		if err == nil && config.minFreeSpace > 0 && (actionName(action) == actionMove || action == actionCopy) {
+			err = checkFreeSpace(filepath.Dir(destPath), size, config.minFreeSpace)
+		}
+		if errors.Is(err, errInsufficientSpace) {
+			log.Printf("Insufficient space for %s: %v", filename, err)
+			events.publish(Event{Type: eventSkipped, Source: sourcePath, Destination: destPath, Error: err.Error()})
+			p.insufficientSpace()
+			failed = true
+			continue
+		}
+		// replaced is the file on_conflict moved aside, kept until the
+		// pipeline is done so undoing the filing can put it back.
+		var replaced *replacement
+		if err == nil {
+			// Two files with the same name may race for one destination path.
+			err = func() error {
+				unlock := p.locks.lock(destPath)
+				defer unlock()
+				// A re-download of a file already there is filed whatever
+				// the on_conflict policy, which only applies to new content.
+				if config.SkipIdentical && actionName(action) == actionMove {
+					if removed, err := removeIfIdentical(sourcePath, destPath); removed || err != nil {
+						return err
+					}
+				}
+				resolved, moved, err := resolveConflict(config.Destinations[target.rule].OnConflict, config.OnLongPath, action, sourcePath, destPath)
+				if err != nil {
+					return err
+				}
+				destPath = resolved
+				log.Printf("Filing (%s): %s -> %s", actionName(action), sourcePath, destPath)
+				if recipient := config.Destinations[target.rule].recipient; recipient != nil {
+					err = fileEncrypted(action, sourcePath, destPath, recipient)
+				} else {
+					err = mover(action, sourcePath, destPath)
+				}
+				if err != nil {
+					moved.done(false)
+				} else {
+					replaced = moved
+				}
+				return err
+			}()
+		}
+		if errors.Is(err, errAlreadyFiled) {
+			log.Printf("Already filed: %s", destPath)
+			continue
+		}
+		if errors.Is(err, errConflictSkipped) || errors.Is(err, errPathTooLong) {
+			log.Printf("Skipping %s: %v", filename, err)
+			events.publish(Event{Type: eventSkipped, Source: sourcePath, Error: err.Error()})
+			failed = true
+			continue
+		}
+		if errors.Is(err, errSourceGone) {
+			log.Printf("Source disappeared before it could be filed: %s", sourcePath)
+			p.vanished()
+			return
+		}
+		if err != nil {
+			log.Printf("Error moving %s: %v", filename, err)
+			events.publish(Event{Type: eventError, Source: sourcePath, Destination: destPath, Error: err.Error()})
+			p.errored()
+			failed = true
+			continue
+		}
+
+		if len(config.Destinations[target.rule].Pipeline) > 0 {
+			if err := runPipeline(config.Destinations[target.rule], sourcePath, destPath); err != nil {
+				log.Printf("%v", err)
+				events.publish(Event{Type: eventError, Source: sourcePath, Destination: destPath, Error: err.Error()})
+				if config.Destinations[target.rule].PipelineUndoOnFailure {
+					if undoErr := undoFiling(action, sourcePath, destPath); undoErr != nil {
+						log.Printf("failed to undo filing of %s: %v", filename, undoErr)
+						if replaced != nil {
+							log.Printf("Keeping the file it replaced at %s", replaced.backup)
+						}
+					} else {
+						log.Printf("Undid filing of %s after pipeline failure", filename)
+						replaced.done(false)
+					}
+					p.errored()
+					failed = true
+					continue
+				}
+			}
+		}
+
+		replaced.done(true)
+		log.Printf("Success: %s", filename)
+		filed++
+		dirNames.filed(destPath)
+		fileSidecar(config, action, sourcePath, destPath)
+		tagFiledFile(destPath, config.Destinations[target.rule])
+		if config.Destinations[target.rule].KeepNewestMatching {
+			keepNewest(config, target.rule, destPath)
+		}
+		p.moved(FileMove{Source: sourcePath, Destination: destPath, Size: size, Rule: target.rule})
+		events.publish(Event{Type: eventMoved, Source: sourcePath, Destination: destPath})
+	}
+
+	if removeSource && !failed {
+		if err := os.Remove(sourcePath); err != nil {
+			log.Printf("failed to remove source file: %v", err)
+		}
+		if config.SidecarSuffix != "" {
+			// The sidecar was copied along with the file.
+			os.Remove(sourcePath + config.SidecarSuffix)
+		}
+	}
+	if filed == 0 {
+		p.skipped()
+	}
+}
+
+// pathLocks hands out one mutex per path.
+type pathLocks struct {
+	mu    sync.Mutex
+	locks map[string]*sync.Mutex
+}
+
+// lock locks path and returns the matching unlock function.
+func (l *pathLocks) lock(path string) func() {
+	l.mu.Lock()
+	m, ok := l.locks[path]
+	if !ok {
+		m = &sync.Mutex{}
+		l.locks[path] = m
+	}
+	l.mu.Unlock()
+
+	m.Lock()
+	return m.Unlock
+}
+
+// removeIfIdentical removes sourcePath when destPath already holds the same
+// content, so a re-download of an already filed file counts as done, and
+// reports whether it did. An encrypted destPath is compared through its
+// recorded plaintext hash.
+func removeIfIdentical(sourcePath, destPath string) (bool, error) {
+	same := alreadyEncrypted(sourcePath, destPath)
+	if !same && !strings.HasSuffix(destPath, encryptedSuffix) {
+		same, _ = sameContent(sourcePath, destPath)
+	}
+	if !same {
+		return false, nil
+	}
+
+	log.Printf("Destination already has identical content, removing source: %s", sourcePath)
+	if err := os.Remove(sourcePath); err != nil {
+		log.Printf("failed to remove source file: %v", err)
+		return false, fmt.Errorf("failed to remove source file: %w", err)
+	}
+	return true, nil
+}
+
+// formatBytes renders n as a human-readable size using binary units, e.g.
+// "1.2 GiB".
+func formatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
+
+// printUnused prints the destinations that matched no files in result and
+// returns how many there were.
+func printUnused(config *Config, result *OrganizeResult) int {
+	unused := unusedDestinations(result)
+	for _, i := range unused {
+		dest := config.Destinations[i]
+		fmt.Printf("unused: destination[%d] path=%q prefix=%q suffix=%q\n", i, dest.Path, dest.Prefix, dest.Suffix)
+		log.Printf("Unused destination[%d]: %s", i, dest.Path)
+	}
+	fmt.Printf("%d of %d destinations matched no files\n", len(unused), len(config.Destinations))
+	return len(unused)
+}
+
+// unusedDestinations returns the indices of destinations that matched no
+// files in result.
+func unusedDestinations(result *OrganizeResult) []int {
+	var unused []int
+	for i, count := range result.RuleMatches {
+		if count == 0 {
+			unused = append(unused, i)
+		}
+	}
+	return unused
+}
+
+const defaultDebounce = 5 * time.Second
+
+// debounceFor returns how long to wait after an event on name before
+// organizing: the matching destination's debounce_seconds if set, otherwise
+// the global one.
+func debounceFor(config *Config, name string) time.Duration {
+	delay := defaultDebounce
+	if config.DebounceSeconds > 0 {
+		delay = seconds(config.DebounceSeconds)
+	}
+	if i, ok := config.matchDestination(filepath.Base(name)); ok && config.Destinations[i].DebounceSeconds > 0 {
+		delay = seconds(config.Destinations[i].DebounceSeconds)
+	}
+	return delay
+}
+
+func seconds(s float64) time.Duration {
+	return time.Duration(s * float64(time.Second))
+}
+
+type fileOrganizer struct {
+	timer   Timer
+	timerMu sync.Mutex
+
+	// delay is the shortest debounce requested by the events seen since the
+	// last pass; it is reset when the timer fires.
+	delay time.Duration
+
+	// settleTimer runs the one follow-up pass scheduled by settle_seconds.
+	// It is guarded by timerMu.
+	settleTimer Timer
+
+	// recheckTimer runs the pass that re-checks files left by
+	// stable_for_seconds. It is guarded by timerMu.
+	recheckTimer Timer
+
+	// storm tracks the event rate for storm_events_per_second. It is
+	// guarded by timerMu.
+	storm stormDetector
+
+	// runMu serializes organize passes so a resume can't race the timer.
+	runMu sync.Mutex
+
+	// config, once a reload has stored one, is used by every pass instead
+	// of the config it was scheduled with, so a reload also applies to
+	// passes already pending.
+	config atomic.Pointer[Config]
+
+	// paused is toggled by SIGUSR1/SIGUSR2. pending records that a pass was
+	// requested while paused, so a single pass runs on resume.
+	paused  atomic.Bool
+	pending atomic.Bool
+}
+
+// schedule (re)starts the debounce timer for an event on name. The timer uses
+// the shortest debounce of all events since the last pass, so a slow rule
+// never delays a fast one.
+func (o *fileOrganizer) schedule(config *Config, name string) {
+	o.timerMu.Lock()
+	defer o.timerMu.Unlock()
+
+	if delay := debounceFor(config, name); o.delay == 0 || delay < o.delay {
+		o.delay = delay
+	}
+	if o.timer != nil {
+		o.timer.Stop()
+	}
+
+	delay := o.delay
+	if config.StormEventsPerSecond > 0 && o.storm.observe(clock.Now(), config.StormEventsPerSecond) {
+		quiet := defaultStormQuiet
+		if config.StormQuietSeconds > 0 {
+			quiet = seconds(config.StormQuietSeconds)
+		}
+		delay = max(delay, quiet)
+	}
+
+	o.timer = clock.AfterFunc(delay, func() {
+		o.timerMu.Lock()
+		o.delay = 0
+		o.storm.quiet()
+		o.timerMu.Unlock()
+
+		log.Println("Timer expired, organizing files...")
+		o.organize(config)
+	})
+}
+
+func (o *fileOrganizer) organize(config *Config) {
+	o.pass(config, true)
+}
+
+// pass runs one organize pass. When settle is set and the pass moved
+// anything, a single follow-up pass is scheduled settle_seconds later to
+// catch files that were being recreated while it ran. The follow-up itself
+// never schedules another.
+func (o *fileOrganizer) pass(config *Config, settle bool) {
+	if o.paused.Load() {
+		o.pending.Store(true)
+		log.Println("Organizing is paused, deferring until resume")
+		return
+	}
+
+	o.runMu.Lock()
+	defer o.runMu.Unlock()
+
+	if reloaded := o.config.Load(); reloaded != nil {
+		config = reloaded
+	}
+	result, err := organizeFiles(config)
+	if err != nil {
+		log.Println(err)
+		return
+	}
+
+	if result.Unstable > 0 {
+		// Finished downloads raise no further events, so come back for them.
+		o.timerMu.Lock()
+		if o.recheckTimer != nil {
+			o.recheckTimer.Stop()
+		}
+		o.recheckTimer = clock.AfterFunc(seconds(config.StableForSeconds), func() {
+			log.Println("Re-checking files that were still changing...")
+			o.pass(config, true)
+		})
+		o.timerMu.Unlock()
+	}
+
+	if settle && config.SettleSeconds > 0 && result.Moved > 0 {
+		o.timerMu.Lock()
+		if o.settleTimer != nil {
+			o.settleTimer.Stop()
+		}
+		o.settleTimer = clock.AfterFunc(seconds(config.SettleSeconds), func() {
+			log.Println("Running settling re-check...")
+			o.pass(config, false)
+		})
+		o.timerMu.Unlock()
+	}
+}
+
+// flushTimeout bounds the final organize pass run by flush_on_shutdown.
+const flushTimeout = 30 * time.Second
+
+// flush runs the organize pass a stopped timer would have run, giving up
+// after timeout so shutdown can't hang.
+func (o *fileOrganizer) flush(config *Config, timeout time.Duration) {
+	log.Println("Flushing pending organize before shutdown...")
+
+	done := make(chan struct{})
+	go func() {
+		o.organize(config)
+		close(done)
+	}()
+
+	expired := make(chan struct{})
+	timer := clock.AfterFunc(timeout, func() { close(expired) })
+	defer timer.Stop()
+
+	select {
+	case <-done:
+		log.Println("Flush complete")
+	case <-expired:
+		log.Printf("Flush did not finish within %v, exiting anyway", timeout)
+	}
+}
+
+// defaultShutdownTimeout bounds how long shutdown waits for a running pass.
+const defaultShutdownTimeout = 60 * time.Second
+
+// drain waits up to timeout for a running organize pass to finish and
+// reports whether it did. On success it keeps runMu held, so no further
+// pass can start while the process exits.
+func (o *fileOrganizer) drain(timeout time.Duration) bool {
+	done := make(chan struct{})
+	go func() {
+		o.runMu.Lock()
+		close(done)
+	}()
+
+	expired := make(chan struct{})
+	timer := clock.AfterFunc(timeout, func() { close(expired) })
+	defer timer.Stop()
+
+	select {
+	case <-done:
+		return true
+	case <-expired:
+		return false
+	}
+}
+
+func (o *fileOrganizer) pause() {
+	if o.paused.CompareAndSwap(false, true) {
+		log.Println("Organizing paused (send SIGUSR2 to resume)")
+	}
+}
+
+func (o *fileOrganizer) resume(config *Config) {
+	if !o.paused.CompareAndSwap(true, false) {
+		return
+	}
+	log.Println("Organizing resumed")
+	if o.pending.Swap(false) {
+		log.Println("Running organize deferred while paused...")
+		o.organize(config)
+	}
+}
@@ -0,0 +1,241 @@
+package organize
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestPanickingMoveDoesNotStopPass(t *testing.T) {
+	dir := t.TempDir()
+	dump := filepath.Join(dir, "dump")
+	out := filepath.Join(dir, "out")
+	for _, name := range []string{"a_1.txt", "a_2.txt", "a_3.txt"} {
+		writeFile(t, filepath.Join(dump, name), name, time.Time{})
+	}
+	mover = func(action, sourcePath, destPath string) error {
+		if filepath.Base(sourcePath) == "a_2.txt" {
+			panic("pathological file")
+		}
+		return fileTo(action, sourcePath, destPath)
+	}
+	t.Cleanup(func() { mover = fileTo })
+
+	config := &Config{DumpDirectory: dump, Destinations: []Destination{{Prefix: "a_", Path: out}}}
+	if err := config.compile(); err != nil {
+		t.Fatal(err)
+	}
+	result, err := organizeWith(config, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, name := range []string{"a_1.txt", "a_3.txt"} {
+		if got := readFile(t, filepath.Join(out, name)); got != name {
+			t.Errorf("%s = %q, want it filed", name, got)
+		}
+	}
+	if got := readFile(t, filepath.Join(dump, "a_2.txt")); got != "a_2.txt" {
+		t.Errorf("a_2.txt = %q, want it left in the dump directory", got)
+	}
+	if _, err := os.Lstat(filepath.Join(out, "a_2.txt")); !os.IsNotExist(err) {
+		t.Errorf("a_2.txt filed despite the panic: %v", err)
+	}
+	if result.Moved != 2 || result.Errors != 1 {
+		t.Errorf("moved %d, errors %d, want 2 and 1", result.Moved, result.Errors)
+	}
+}
+
+func TestSafeJoinEscapes(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "out")
+	tests := []struct {
+		name    string
+		want    string
+		wantErr bool
+	}{
+		{name: "report.pdf", want: filepath.Join(dir, "report.pdf")},
+		// Percent-encoding is not decoded, so this is one odd file name.
+		{name: "..%2f..%2fetc", want: filepath.Join(dir, "..%2f..%2fetc")},
+		{name: "..report", want: filepath.Join(dir, "..report")},
+		{name: "../../etc/passwd", want: filepath.Join(dir, "passwd")},
+		{name: "a/../../../etc", want: filepath.Join(dir, "etc")},
+		{name: "/etc/passwd", want: filepath.Join(dir, "passwd")},
+		{name: "sub/", want: filepath.Join(dir, "sub")},
+		{name: "..", wantErr: true},
+		{name: "../..", wantErr: true},
+		{name: "a/..", wantErr: true},
+		{name: ".", wantErr: true},
+		{name: "", wantErr: true},
+		{name: "/", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := safeJoin(dir, tt.name)
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("safeJoin(%q) = %q, want an error", tt.name, got)
+				}
+				return
+			}
+			if err != nil || got != tt.want {
+				t.Fatalf("safeJoin(%q) = %q, %v, want %q", tt.name, got, err, tt.want)
+			}
+			if rel, err := filepath.Rel(dir, got); err != nil || filepath.Dir(rel) != "." {
+				t.Errorf("safeJoin(%q) = %q, not directly under %s", tt.name, got, dir)
+			}
+		})
+	}
+}
+
+func TestTraversalNameFiledInsideDestination(t *testing.T) {
+	dir := t.TempDir()
+	dump := filepath.Join(dir, "dump")
+	out := filepath.Join(dir, "nested", "out")
+	writeFile(t, filepath.Join(dump, "..%2f..%2fetc"), "data", time.Time{})
+	config := &Config{DumpDirectory: dump, Destinations: []Destination{{Prefix: "..", Path: out}}}
+	if err := config.compile(); err != nil {
+		t.Fatal(err)
+	}
+	result, err := organizeWith(config, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Moved != 1 {
+		t.Fatalf("moved %d files, want 1", result.Moved)
+	}
+	if got := readFile(t, filepath.Join(out, "..%2f..%2fetc")); got != "data" {
+		t.Errorf("filed content = %q, want %q", got, "data")
+	}
+	entries, err := os.ReadDir(filepath.Join(dir, "nested"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 || entries[0].Name() != "out" {
+		t.Errorf("files escaped the destination into %s: %v", filepath.Join(dir, "nested"), entries)
+	}
+}
+
+func TestMatchAllSkipsDuplicateDestinationPath(t *testing.T) {
+	dir := t.TempDir()
+	dump := filepath.Join(dir, "dump")
+	out := filepath.Join(dir, "out")
+	other := filepath.Join(dir, "other")
+	writeFile(t, filepath.Join(dump, "IMG_1.jpg"), "photo", time.Time{})
+	// The first two rules resolve to the same path, written differently.
+	// Were the second not skipped, its symlink would collide with the copy
+	// the first just made.
+	config := &Config{DumpDirectory: dump, MatchAll: true, Destinations: []Destination{
+		{Prefix: "IMG_", Path: out},
+		{Suffix: ".jpg", Path: out + string(filepath.Separator), Action: actionSymlink},
+		{Suffix: ".jpg", Path: other},
+	}}
+	if err := config.compile(); err != nil {
+		t.Fatal(err)
+	}
+	result, err := organizeWith(config, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Errors != 0 || len(result.Moves) != 2 {
+		t.Errorf("errors %d, moves %v, want no errors and one move to each directory", result.Errors, result.Moves)
+	}
+	for _, path := range []string{filepath.Join(out, "IMG_1.jpg"), filepath.Join(other, "IMG_1.jpg")} {
+		if got := readFile(t, path); got != "photo" {
+			t.Errorf("%s = %q, want %q", path, got, "photo")
+		}
+	}
+	if entries, _ := os.ReadDir(out); len(entries) != 1 {
+		t.Errorf("out has %d entries, want only IMG_1.jpg", len(entries))
+	}
+	if _, err := os.Lstat(filepath.Join(dump, "IMG_1.jpg")); !os.IsNotExist(err) {
+		t.Errorf("source left after matching every destination: %v", err)
+	}
+}
+
+func TestSourceRemovedBeforeMove(t *testing.T) {
+	for _, crossDevice := range []bool{false, true} {
+		name := "rename"
+		if crossDevice {
+			name = "copy across devices"
+		}
+		t.Run(name, func(t *testing.T) {
+			dir := t.TempDir()
+			dump := filepath.Join(dir, "dump")
+			out := filepath.Join(dir, "out")
+			writeFile(t, filepath.Join(dump, "a_gone.txt"), "gone", time.Time{})
+			writeFile(t, filepath.Join(dump, "a_kept.txt"), "kept", time.Time{})
+			// Another process takes a_gone.txt just as it is moved.
+			rename = func(oldpath, newpath string) error {
+				if filepath.Base(oldpath) == "a_gone.txt" {
+					if err := os.Remove(oldpath); err != nil {
+						t.Fatal(err)
+					}
+				}
+				if crossDevice {
+					return &os.LinkError{Op: "rename", Old: oldpath, New: newpath, Err: syscall.EXDEV}
+				}
+				return os.Rename(oldpath, newpath)
+			}
+			t.Cleanup(func() { rename = os.Rename })
+
+			config := &Config{DumpDirectory: dump, Destinations: []Destination{{Prefix: "a_", Path: out}}}
+			if err := config.compile(); err != nil {
+				t.Fatal(err)
+			}
+			result, err := organizeWith(config, 1)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if result.Moved != 1 || result.Vanished != 1 || result.Errors != 0 {
+				t.Errorf("moved %d, vanished %d, errors %d, want 1, 1, 0", result.Moved, result.Vanished, result.Errors)
+			}
+			if got := readFile(t, filepath.Join(out, "a_kept.txt")); got != "kept" {
+				t.Errorf("a_kept.txt = %q, want %q", got, "kept")
+			}
+			if _, err := os.Lstat(filepath.Join(out, "a_gone.txt")); !os.IsNotExist(err) {
+				t.Errorf("vanished file filed: %v", err)
+			}
+		})
+	}
+}
+
+func TestStrictConfigDecoding(t *testing.T) {
+	tests := []struct {
+		name    string
+		config  string
+		rule    string // contents of rules.d/extra.yaml, if any
+		wantErr string
+	}{
+		{name: "valid", config: "destinations:\n  - prefix: a_\n    path: /tmp/a\n"},
+		{name: "empty document", config: ""},
+		{name: "unknown top-level field", config: "debounce_secs: 2\n", wantErr: "field debounce_secs not found"},
+		{name: "unknown destination field", config: "destinations:\n  - prefix: a_\n    paht: /tmp/a\n", wantErr: "field paht not found"},
+		{name: "duplicate top-level key", config: "debounce_seconds: 2\ndebounce_seconds: 3\n", wantErr: `"debounce_seconds" already defined`},
+		{name: "duplicate destination key", config: "destinations:\n  - prefix: a_\n    path: /tmp/a\n    path: /tmp/b\n", wantErr: `"path" already defined`},
+		{name: "unknown field in rules.d", rule: "- prefix: b_\n  path: /tmp/b\n  sufix: .txt\n", wantErr: "field sufix not found"},
+		{name: "duplicate key in rules.d", rule: "- prefix: b_\n  prefix: c_\n  path: /tmp/b\n", wantErr: `"prefix" already defined`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			path := filepath.Join(dir, "prefix.yaml")
+			writeFile(t, path, "dump_directory: "+dir+"\n"+tt.config, time.Time{})
+			if tt.rule != "" {
+				writeFile(t, filepath.Join(dir, "rules.d", "extra.yaml"), tt.rule, time.Time{})
+			}
+			_, err := loadConfigFile(path, "")
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("loadConfigFile = %v, want no error", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("loadConfigFile error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
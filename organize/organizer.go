@@ -0,0 +1,250 @@
+package organize
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/robfig/cron/v3"
+)
+
+// Organizer watches the dump directory and organizes it as files arrive,
+// along with the event socket and schedule if configured. It lets the
+// organizer run in-process in a larger program; Run is a thin wrapper
+// around it. The initial pass over existing files is left to the caller.
+type Organizer struct {
+	config atomic.Pointer[Config]
+	files  *fileOrganizer
+
+	watcher   *fsnotify.Watcher
+	listener  net.Listener
+	scheduler *cron.Cron
+
+	// watching is closed when the event loop exits; stopped when Stop is
+	// called.
+	watching chan struct{}
+	stopped  chan struct{}
+	stopOnce sync.Once
+
+	// configDir is where the config file lives, watched to reload it.
+	// includes are the config's patterns_file and hash list files, and
+	// includeDirs the directories watched only for them. reloadTimer
+	// debounces their events. All but configDir are guarded by reloadMu.
+	configDir   string
+	reloadMu    sync.Mutex
+	includes    map[string]bool
+	includeDirs map[string]bool
+	reloadTimer Timer
+}
+
+// NewOrganizer returns an Organizer for a config from LoadConfig that has
+// passed Validate.
+func NewOrganizer(config *Config) *Organizer {
+	o := &Organizer{
+		files:    &fileOrganizer{},
+		watching: make(chan struct{}),
+		stopped:  make(chan struct{}),
+	}
+	o.config.Store(config)
+	return o
+}
+
+// Start begins watching and returns once everything is set up. The
+// organizer runs until Stop is called or ctx is cancelled.
+func (o *Organizer) Start(ctx context.Context) error {
+	config := o.config.Load()
+	applySettings(config)
+
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return fmt.Errorf("failed to create watcher: %w", err)
+	}
+	o.watcher = watcher
+	go o.watch()
+
+	for _, dir := range config.dumpDirs() {
+		if err := watchTree(watcher, dir, config.Recursive); err != nil {
+			o.Stop()
+			return fmt.Errorf("failed to add watcher: %w", err)
+		}
+	}
+	o.watchConfig()
+
+	if config.SocketPath != "" {
+		listener, err := serveEvents(config.SocketPath)
+		if err != nil {
+			o.Stop()
+			return fmt.Errorf("failed to listen on event socket: %w", err)
+		}
+		o.listener = listener
+		log.Printf("Streaming events on %s", config.SocketPath)
+	}
+
+	if config.Schedule != "" {
+		scheduler, err := startSchedule(config, o.files)
+		if err != nil {
+			o.Stop()
+			return err
+		}
+		o.scheduler = scheduler
+		log.Printf("Organizing on schedule: %s", config.Schedule)
+	}
+
+	if interval := o.summaryInterval(); interval > 0 {
+		go logSummaries(interval, o.stopped)
+		log.Printf("Logging stats every %v", interval)
+	}
+
+	go func() {
+		select {
+		case <-ctx.Done():
+			o.Stop()
+		case <-o.stopped:
+		}
+	}()
+	return nil
+}
+
+// summaryInterval is how often stats are logged: --summary-interval if
+// given, else summary_interval_minutes. Zero means never.
+func (o *Organizer) summaryInterval() time.Duration {
+	if options.SummaryInterval > 0 {
+		return options.SummaryInterval
+	}
+	return time.Duration(o.config.Load().SummaryIntervalMinutes * float64(time.Minute))
+}
+
+// watch schedules an organize pass for every file event until the watcher
+// is closed.
+func (o *Organizer) watch() {
+	defer close(o.watching)
+
+	for {
+		select {
+		case event, ok := <-o.watcher.Events:
+			if !ok {
+				return
+			}
+			if o.isConfigEvent(event) {
+				continue
+			}
+
+			config := o.config.Load()
+			log.Println(event)
+			info, statErr := os.Stat(event.Name)
+			isDir := statErr == nil && info.IsDir()
+			if config.Recursive && isDir && event.Has(fsnotify.Create) {
+				if err := watchTree(o.watcher, event.Name, true); err != nil {
+					log.Printf("failed to watch %s: %v", event.Name, err)
+				}
+			}
+			// Files no rule could match don't need a pass, and skipping them
+			// keeps unrelated churn from resetting the debounce timer.
+			// Directories always count: files moved in with them raise no
+			// events of their own.
+			if !isDir && !config.mightMatch(filepath.Base(event.Name)) {
+				continue
+			}
+			o.files.schedule(config, event.Name)
+
+		case err, ok := <-o.watcher.Errors:
+			if !ok {
+				return
+			}
+			log.Println("Error:", err)
+		}
+	}
+}
+
+// Pause defers organize passes until Resume.
+func (o *Organizer) Pause() {
+	o.files.pause()
+}
+
+// Resume runs any pass deferred while paused and reacts to events again.
+func (o *Organizer) Resume() {
+	o.files.resume(o.config.Load())
+}
+
+// Stop stops watching, cancels the schedule and any pending or settling
+// pass, and runs the pending pass first if flush_on_shutdown is set. It is
+// safe to call more than once; later calls wait for the first to finish.
+func (o *Organizer) Stop() {
+	o.stop(false)
+}
+
+// Drain is Stop for a clean restart: once no new events are taken, it
+// runs one final full organize pass, even while paused, and waits for it
+// however long it takes.
+func (o *Organizer) Drain() {
+	o.stop(true)
+}
+
+func (o *Organizer) stop(drain bool) {
+	o.stopOnce.Do(func() {
+		close(o.stopped)
+		config := o.config.Load()
+
+		// Stop taking events first so nothing re-arms the timer below.
+		if o.watcher != nil {
+			o.watcher.Close()
+			<-o.watching
+		}
+		o.reloadMu.Lock()
+		if o.reloadTimer != nil {
+			o.reloadTimer.Stop()
+		}
+		o.reloadMu.Unlock()
+
+		if o.scheduler != nil {
+			o.scheduler.Stop()
+			log.Println("Stopped organize schedule")
+		}
+
+		files := o.files
+		files.timerMu.Lock()
+		pending := false
+		if files.timer != nil {
+			pending = files.timer.Stop()
+			log.Println("Stopped file organization timer")
+		}
+		if files.settleTimer != nil {
+			files.settleTimer.Stop()
+		}
+		if files.recheckTimer != nil {
+			files.recheckTimer.Stop()
+		}
+		files.timerMu.Unlock()
+
+		if drain {
+			log.Println("Draining: running a final organize pass...")
+			files.paused.Store(false)
+			files.pass(config, false)
+			log.Println("Final organize pass done")
+		} else if pending && config.FlushOnShutdown {
+			files.flush(config, flushTimeout)
+		}
+
+		timeout := defaultShutdownTimeout
+		if config.ShutdownTimeoutSeconds > 0 {
+			timeout = seconds(config.ShutdownTimeoutSeconds)
+		}
+		if files.drain(timeout) {
+			log.Println("No organize pass in flight, drained cleanly")
+		} else {
+			log.Printf("Organize pass still running after %v, exiting anyway; run \"prefix cleanup\" to remove any partial copy", timeout)
+		}
+
+		if o.listener != nil {
+			o.listener.Close()
+			os.Remove(config.SocketPath)
+		}
+	})
+}
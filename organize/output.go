@@ -0,0 +1,31 @@
+package organize
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+)
+
+const (
+	outputText = "text"
+	outputJSON = "json"
+)
+
+// printResult writes the summary of a --once pass to stdout, as one line
+// of text or as the OrganizeResult in JSON.
+func printResult(result *OrganizeResult, format string) error {
+	if format == outputJSON {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(result)
+	}
+
+	if result.DryRun {
+		fmt.Printf("Dry run: %d files would be filed (%s), %d skipped in %.1fs\n",
+			result.Moved, formatBytes(result.BytesMoved), result.Skipped, result.DurationSeconds)
+		return nil
+	}
+	fmt.Printf("%d files moved (%s), %d skipped, %d errors in %.1fs\n",
+		result.Moved, formatBytes(result.BytesMoved), result.Skipped, result.Errors, result.DurationSeconds)
+	return nil
+}
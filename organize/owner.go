@@ -0,0 +1,50 @@
+package organize
+
+import (
+	"fmt"
+	"os/user"
+	"strconv"
+	"strings"
+)
+
+// resolveOwner parses a match_owner value of the form "user", ":group" or
+// "user:group" into numeric ids, with -1 meaning "any".
+func resolveOwner(spec string) (uid, gid int, err error) {
+	uid, gid = -1, -1
+	userName, groupName, _ := strings.Cut(spec, ":")
+
+	if userName != "" {
+		u, err := user.Lookup(userName)
+		if err != nil {
+			return -1, -1, fmt.Errorf("unknown user %q: %w", userName, err)
+		}
+		if uid, err = strconv.Atoi(u.Uid); err != nil {
+			return -1, -1, fmt.Errorf("user %q has non-numeric uid %q", userName, u.Uid)
+		}
+	}
+
+	if groupName != "" {
+		g, err := user.LookupGroup(groupName)
+		if err != nil {
+			return -1, -1, fmt.Errorf("unknown group %q: %w", groupName, err)
+		}
+		if gid, err = strconv.Atoi(g.Gid); err != nil {
+			return -1, -1, fmt.Errorf("group %q has non-numeric gid %q", groupName, g.Gid)
+		}
+	}
+
+	if uid == -1 && gid == -1 {
+		return -1, -1, fmt.Errorf("match_owner %q names no user or group", spec)
+	}
+	return uid, gid, nil
+}
+
+// matchesOwner reports whether the file at path is owned by the
+// destination's match_owner.
+func matchesOwner(path string, dest Destination) bool {
+	uid, gid, ok := fileOwner(path)
+	if !ok {
+		return false
+	}
+	return (dest.ownerUID == -1 || dest.ownerUID == uid) && (dest.ownerGID == -1 || dest.ownerGID == gid)
+}
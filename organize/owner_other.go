@@ -0,0 +1,15 @@
+//go:build !unix
+
+package organize
+
+import "os"
+
+const ownerSupported = false
+
+func fileOwner(path string) (uid, gid int, ok bool) {
+	return 0, 0, false
+}
+
+func infoOwner(info os.FileInfo) (uid, gid int, ok bool) {
+	return 0, 0, false
+}
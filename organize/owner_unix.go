@@ -0,0 +1,26 @@
+//go:build unix
+
+package organize
+
+import (
+	"os"
+	"syscall"
+)
+
+const ownerSupported = true
+
+func fileOwner(path string) (uid, gid int, ok bool) {
+	info, err := os.Lstat(path)
+	if err != nil {
+		return 0, 0, false
+	}
+	return infoOwner(info)
+}
+
+func infoOwner(info os.FileInfo) (uid, gid int, ok bool) {
+	stat, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return 0, 0, false
+	}
+	return int(stat.Uid), int(stat.Gid), true
+}
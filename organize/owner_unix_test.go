@@ -0,0 +1,58 @@
+//go:build unix
+
+package organize
+
+import (
+	"os"
+	"os/user"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestMatchOwner(t *testing.T) {
+	me, err := user.Current()
+	if err != nil {
+		t.Skipf("can't look up the current user: %v", err)
+	}
+	group, err := user.LookupGroupId(me.Gid)
+	if err != nil {
+		t.Skipf("can't look up the current group: %v", err)
+	}
+	dir := t.TempDir()
+	path := filepath.Join(dir, "report.pdf")
+	writeFile(t, path, "data", time.Time{})
+
+	for _, spec := range []string{me.Username, ":" + group.Name, me.Username + ":" + group.Name} {
+		t.Run(spec, func(t *testing.T) {
+			config := &Config{DumpDirectory: dir, Destinations: []Destination{{Suffix: ".pdf", MatchOwner: spec, Path: dir}}}
+			if err := config.compile(); err != nil {
+				t.Fatal(err)
+			}
+			if !matchesOwner(path, config.Destinations[0]) {
+				t.Errorf("file created by %s doesn't match match_owner %q", me.Username, spec)
+			}
+
+			// The same rule for another owner doesn't match.
+			dest := config.Destinations[0]
+			if dest.ownerUID != -1 {
+				dest.ownerUID = os.Getuid() + 1
+			} else {
+				dest.ownerGID = os.Getgid() + 1
+			}
+			if matchesOwner(path, dest) {
+				t.Errorf("file matches uid %d, gid %d", dest.ownerUID, dest.ownerGID)
+			}
+		})
+	}
+
+	if matchesOwner(filepath.Join(dir, "missing.pdf"), Destination{ownerUID: os.Getuid(), ownerGID: -1}) {
+		t.Error("a missing file matches its owner")
+	}
+	for _, spec := range []string{"no-such-user-prefix-test", ":no-such-group-prefix-test", ":"} {
+		config := &Config{DumpDirectory: dir, Destinations: []Destination{{Suffix: ".pdf", MatchOwner: spec, Path: dir}}}
+		if err := config.compile(); err == nil {
+			t.Errorf("compile accepted match_owner %q", spec)
+		}
+	}
+}
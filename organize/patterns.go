@@ -0,0 +1,102 @@
+package organize
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// readPatterns reads a patterns file: one pattern per line, written
+// "prefix:<text>", "suffix:<text>" or just "<text>" for a prefix. Blank
+// lines and lines starting with # are ignored.
+func readPatterns(path string) ([]Destination, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var patterns []Destination
+	scanner := bufio.NewScanner(file)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+
+		var p Destination
+		kind, value, found := strings.Cut(text, ":")
+		switch {
+		case !found:
+			p.Prefix = text
+		case kind == "prefix":
+			p.Prefix = value
+		case kind == "suffix":
+			p.Suffix = value
+		default:
+			return nil, fmt.Errorf("%s:%d: unknown pattern kind %q, want prefix or suffix", path, line, kind)
+		}
+		if p.Prefix == "" && p.Suffix == "" {
+			return nil, fmt.Errorf("%s:%d: empty pattern", path, line)
+		}
+		patterns = append(patterns, p)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return patterns, nil
+}
+
+// expandPatternsFiles replaces each destination that has a patterns_file
+// with one destination per pattern in the file, in order, sharing the
+// destination's other fields. Relative paths are resolved against dir, the
+// directory the config file is in.
+func (c *Config) expandPatternsFiles(dir string) error {
+	var errs []error
+	expanded := make([]Destination, 0, len(c.Destinations))
+	for i, dest := range c.Destinations {
+		if dest.PatternsFile == "" {
+			expanded = append(expanded, dest)
+			continue
+		}
+		if dest.Prefix != "" || dest.Suffix != "" || len(dest.Routes) > 0 {
+			errs = append(errs, fmt.Errorf("destination[%d]: patterns_file can't be combined with prefix, suffix or routes", i))
+			continue
+		}
+
+		path := dest.PatternsFile
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(dir, path)
+		}
+		c.includes = append(c.includes, filepath.Clean(path))
+		patterns, err := readPatterns(path)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("destination[%d]: failed to read patterns_file: %w", i, err))
+			continue
+		}
+		if len(patterns) == 0 {
+			errs = append(errs, fmt.Errorf("destination[%d]: patterns_file %s has no patterns", i, path))
+			continue
+		}
+
+		for _, p := range patterns {
+			d := dest
+			d.PatternsFile = ""
+			d.Prefix = p.Prefix
+			d.Suffix = p.Suffix
+			if dest.Name != "" {
+				d.Name = dest.Name + "." + p.Prefix + p.Suffix
+				d.group = dest.Name
+			}
+			expanded = append(expanded, d)
+		}
+	}
+	if len(errs) > 0 {
+		return errors.Join(errs...)
+	}
+	c.Destinations = expanded
+	return nil
+}
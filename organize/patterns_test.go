@@ -0,0 +1,81 @@
+package organize
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestPatternsFile(t *testing.T) {
+	dir := t.TempDir()
+	out := filepath.Join(dir, "out")
+	path := filepath.Join(dir, "prefix.yaml")
+	writeFile(t, path, "dump_directory: "+filepath.Join(dir, "dump")+`
+destinations:
+  - path: `+out+`
+    patterns_file: lists/patterns.txt
+  - prefix: z_
+    path: `+filepath.Join(dir, "z")+`
+`, time.Time{})
+	writeFile(t, filepath.Join(dir, "lists", "patterns.txt"), "# shared with other tools\nINV_\n\nprefix:RCPT_\nsuffix:.ofx\n", time.Time{})
+
+	config, err := loadConfigFile(path, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(config.Destinations) != 4 {
+		t.Fatalf("got %d destinations, want 3 from the patterns file and z_", len(config.Destinations))
+	}
+	for _, tt := range []struct {
+		filename string
+		want     string
+	}{
+		{"INV_0001.pdf", out},
+		{"RCPT_march.jpg", out},
+		{"bank.ofx", out},
+		{"z_notes.txt", filepath.Join(dir, "z")},
+	} {
+		dest, ok := config.Classify(tt.filename)
+		if !ok || dest.Path != tt.want {
+			t.Errorf("Classify(%q) = %q, %v, want %q", tt.filename, dest.Path, ok, tt.want)
+		}
+	}
+	if _, ok := config.Classify("notes.txt"); ok {
+		t.Error("notes.txt matched a pattern")
+	}
+}
+
+func TestPatternsFileErrors(t *testing.T) {
+	tests := []struct {
+		name     string
+		patterns string // "" for no file
+		extra    string
+		wantErr  string
+	}{
+		{name: "missing file", wantErr: "failed to read patterns_file"},
+		{name: "unknown kind", patterns: "glob:*.pdf\n", wantErr: `patterns.txt:1: unknown pattern kind "glob"`},
+		{name: "empty pattern", patterns: "INV_\nsuffix:\n", wantErr: "patterns.txt:2: empty pattern"},
+		{name: "no patterns", patterns: "# nothing yet\n", wantErr: "has no patterns"},
+		{name: "with prefix", patterns: "INV_\n", extra: "    prefix: a_\n", wantErr: "can't be combined"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			path := filepath.Join(dir, "prefix.yaml")
+			writeFile(t, path, "dump_directory: "+dir+"\ndestinations:\n  - path: "+filepath.Join(dir, "out")+"\n    patterns_file: patterns.txt\n"+tt.extra, time.Time{})
+			if tt.patterns != "" {
+				writeFile(t, filepath.Join(dir, "patterns.txt"), tt.patterns, time.Time{})
+			}
+			_, err := loadConfigFile(path, "")
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("loadConfigFile error = %v, want %q", err, tt.wantErr)
+			}
+			if tt.patterns == "" && !errors.Is(err, os.ErrNotExist) {
+				t.Errorf("missing file error = %v, want a not-exist error", err)
+			}
+		})
+	}
+}
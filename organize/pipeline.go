@@ -0,0 +1,86 @@
+package organize
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+const defaultStepTimeout = 5 * time.Minute
+
+// PipelineStep is an external command run on a file after it is filed.
+type PipelineStep struct {
+	// Name identifies the step in the log.
+	Name string `yaml:"name"`
+
+	// Command is the program and its arguments. {path}, {dir}, {name},
+	// {stem} and {ext} are replaced with the filed file's path, directory,
+	// name, name without extension and extension; {source} with where it
+	// was found.
+	Command []string `yaml:"command"`
+
+	// TimeoutSeconds bounds how long the step may run. Defaults to 300.
+	TimeoutSeconds float64 `yaml:"timeout_seconds,omitempty"`
+}
+
+// runPipeline runs dest's pipeline steps in order on the file filed from
+// sourcePath to destPath. It stops at the first step that fails and returns
+// its error.
+func runPipeline(dest Destination, sourcePath, destPath string) error {
+	replacer := strings.NewReplacer(append(nameTokens(filepath.Base(destPath)),
+		"{path}", destPath,
+		"{dir}", filepath.Dir(destPath),
+		"{source}", sourcePath,
+	)...)
+
+	for _, step := range dest.Pipeline {
+		args := make([]string, len(step.Command))
+		for i, arg := range step.Command {
+			args[i] = replacer.Replace(arg)
+		}
+		if err := runStep(step, args); err != nil {
+			return fmt.Errorf("pipeline step %q failed for %s: %w", step.Name, destPath, err)
+		}
+		log.Printf("Pipeline step %q done: %s", step.Name, destPath)
+	}
+	return nil
+}
+
+func runStep(step PipelineStep, args []string) error {
+	timeout := defaultStepTimeout
+	if step.TimeoutSeconds > 0 {
+		timeout = seconds(step.TimeoutSeconds)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	output, err := exec.CommandContext(ctx, args[0], args[1:]...).CombinedOutput()
+	if len(output) > 0 {
+		log.Printf("Pipeline step %q output: %s", step.Name, output)
+	}
+	if ctx.Err() != nil {
+		return fmt.Errorf("timed out after %v", timeout)
+	}
+	return err
+}
+
+// undoFiling reverses a file that was filed from sourcePath to destPath
+// with action: a moved file is moved back, anything else filed is removed.
+// Encrypted moves are filed as copies until their pipeline succeeds, so
+// the ciphertext is only ever removed, never moved back over the source.
+func undoFiling(action, sourcePath, destPath string) error {
+	if actionName(action) == actionMove {
+		return moveFile(destPath, sourcePath)
+	}
+	if strings.HasSuffix(destPath, encryptedSuffix) {
+		if err := os.Remove(destPath + encryptedHashSuffix); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	return os.Remove(destPath)
+}
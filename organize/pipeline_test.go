@@ -0,0 +1,142 @@
+package organize
+
+import (
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"filippo.io/age"
+)
+
+// newTestPass returns a passState for config, compiling it first.
+func newTestPass(t *testing.T, config *Config) *passState {
+	t.Helper()
+	if err := config.compile(); err != nil {
+		t.Fatal(err)
+	}
+	return &passState{
+		config: config,
+		locks:  &pathLocks{locks: make(map[string]*sync.Mutex)},
+		result: &OrganizeResult{RuleMatches: make([]int, len(config.Destinations))},
+	}
+}
+
+func TestUndoFiling(t *testing.T) {
+	for _, action := range []string{actionMove, actionCopy, actionSymlink, actionHardlink} {
+		t.Run(action, func(t *testing.T) {
+			dir := t.TempDir()
+			source := filepath.Join(dir, "dump", "a.txt")
+			dest := filepath.Join(dir, "out", "a.txt")
+			writeFile(t, source, "data", time.Time{})
+
+			if err := fileTo(action, source, dest); err != nil {
+				t.Fatal(err)
+			}
+			if err := undoFiling(action, source, dest); err != nil {
+				t.Fatal(err)
+			}
+			if got := readFile(t, source); got != "data" {
+				t.Errorf("source = %q after undo, want %q", got, "data")
+			}
+			if _, err := os.Lstat(dest); !os.IsNotExist(err) {
+				t.Errorf("destination still exists after undo: %v", err)
+			}
+		})
+	}
+}
+
+func TestUndoEncryptedMoveKeepsPlaintext(t *testing.T) {
+	identity, err := age.GenerateX25519Identity()
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	source := filepath.Join(dir, "dump", "a_secret.txt")
+	out := filepath.Join(dir, "out")
+	writeFile(t, source, "plaintext", time.Time{})
+
+	p := newTestPass(t, &Config{
+		DumpDirectory: filepath.Dir(source),
+		Destinations: []Destination{{
+			Prefix:                "a_",
+			Path:                  out,
+			Encrypt:               identity.Recipient().String(),
+			Pipeline:              []PipelineStep{{Name: "fail", Command: []string{"false"}}},
+			PipelineUndoOnFailure: true,
+		}},
+	})
+	p.processFile(candidate{path: source, name: filepath.Base(source)})
+
+	if got := readFile(t, source); got != "plaintext" {
+		t.Errorf("source = %q after undo, want the plaintext", got)
+	}
+	if _, err := os.Lstat(filepath.Join(out, "a_secret.txt.age")); !os.IsNotExist(err) {
+		t.Errorf("ciphertext left in destination after undo: %v", err)
+	}
+	if p.result.Errors != 1 {
+		t.Errorf("errors = %d, want 1", p.result.Errors)
+	}
+}
+
+func TestPipelineEchoStep(t *testing.T) {
+	dir := t.TempDir()
+	source := filepath.Join(dir, "dump", "a_photo.jpg")
+	out := filepath.Join(dir, "out")
+	log := filepath.Join(dir, "steps.log")
+	writeFile(t, source, "jpeg", time.Time{})
+
+	p := newTestPass(t, &Config{
+		DumpDirectory: filepath.Dir(source),
+		Destinations: []Destination{{
+			Prefix:   "a_",
+			Path:     out,
+			Pipeline: []PipelineStep{{Name: "echo", Command: []string{"sh", "-c", `echo "$1" >> "$2"`, "sh", "{path}", log}}},
+		}},
+	})
+	p.processFile(candidate{path: source, name: filepath.Base(source)})
+
+	dest := filepath.Join(out, "a_photo.jpg")
+	if got := readFile(t, log); got != dest+"\n" {
+		t.Errorf("step ran with %q, want the filed path %q", got, dest)
+	}
+	if got := readFile(t, dest); got != "jpeg" || p.result.Moved != 1 {
+		t.Errorf("destination = %q, moved = %d, want the file filed", got, p.result.Moved)
+	}
+}
+
+func TestPipelineUndoRestoresReplaced(t *testing.T) {
+	for _, policy := range []string{onConflictOverwrite, onConflictNewer} {
+		t.Run(policy, func(t *testing.T) {
+			dir := t.TempDir()
+			source := filepath.Join(dir, "dump", "a.txt")
+			out := filepath.Join(dir, "out")
+			dest := filepath.Join(out, "a.txt")
+			writeFile(t, source, "new", time.Time{})
+			writeFile(t, dest, "old", time.Now().Add(-time.Hour))
+
+			p := newTestPass(t, &Config{
+				DumpDirectory: filepath.Dir(source),
+				Destinations: []Destination{{
+					Prefix:                "a",
+					Path:                  out,
+					OnConflict:            policy,
+					Pipeline:              []PipelineStep{{Name: "fail", Command: []string{"false"}}},
+					PipelineUndoOnFailure: true,
+				}},
+			})
+			p.processFile(candidate{path: source, name: "a.txt"})
+
+			if got := readFile(t, source); got != "new" {
+				t.Errorf("source = %q after undo, want %q", got, "new")
+			}
+			if got := readFile(t, dest); got != "old" {
+				t.Errorf("destination = %q after undo, want the replaced file back", got)
+			}
+			if _, err := os.Lstat(dest + replacedSuffix); !os.IsNotExist(err) {
+				t.Errorf("backup left behind: %v", err)
+			}
+		})
+	}
+}
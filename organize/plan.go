@@ -0,0 +1,266 @@
+package organize
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// planEntry is one filing decided by "prefix plan". Size, ModTime and
+// SHA256 describe the source as it was when planned, so apply can tell
+// whether it has changed since. A directory (see include_directories) has
+// no SHA256, and the size and time of its whole tree.
+type planEntry struct {
+	Source      string    `json:"source"`
+	Destination string    `json:"destination"`
+	Rule        int       `json:"rule"`
+	RuleName    string    `json:"rule_name"`
+	Action      string    `json:"action"`
+	Encrypt     string    `json:"encrypt,omitempty"`
+	Size        int64     `json:"size"`
+	ModTime     time.Time `json:"mtime"`
+	SHA256      string    `json:"sha256"`
+
+	// RemoveSource is set on the last entry of a file filed to several
+	// destinations with match_all: the source is removed after it if
+	// every entry for the file succeeded.
+	RemoveSource bool `json:"remove_source,omitempty"`
+}
+
+type plan struct {
+	CreatedAt       time.Time   `json:"created_at"`
+	DumpDirectories []string    `json:"dump_directories"`
+	Entries         []planEntry `json:"entries"`
+}
+
+// cmdPlan works out what an organize pass would do and writes it as JSON,
+// without touching any file, for "prefix apply" to carry out later.
+func cmdPlan(config *Config, args []string) int {
+	flags := flag.NewFlagSet("plan", flag.ContinueOnError)
+	out := flags.String("out", "-", "file to write the plan to, or - for stdout")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+
+	candidates, err := collectCandidates(config.dumpDirs(), config.Recursive, config.includeDirectories)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	p := plan{CreatedAt: clock.Now(), DumpDirectories: config.dumpDirs(), Entries: []planEntry{}}
+	for _, file := range candidates {
+		if config.SidecarSuffix != "" && config.isSidecar(file) {
+			continue
+		}
+		p.Entries = append(p.Entries, planFile(config, file)...)
+	}
+
+	data, err := json.MarshalIndent(p, "", "  ")
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to encode plan: %v\n", err)
+		return 1
+	}
+	data = append(data, '\n')
+	if *out == "-" {
+		os.Stdout.Write(data)
+	} else if err := os.WriteFile(*out, data, 0o644); err != nil {
+		fmt.Fprintf(os.Stderr, "failed to write plan: %v\n", err)
+		return 1
+	} else {
+		fmt.Fprintf(os.Stderr, "Planned %d filing(s), written to %s\n", len(p.Entries), *out)
+	}
+	return 0
+}
+
+// planFile returns the entries for one candidate, applying the same routing
+// as processFile.
+func planFile(config *Config, file candidate) []planEntry {
+	if config.ignored(file.name) {
+		return nil
+	}
+	targets := config.routes(file.path, file.name)
+	if len(targets) == 0 {
+		return nil
+	}
+	size, modTime, hash, err := sourceState(file.path)
+	if err != nil {
+		log.Printf("Not planning %s: %v", file.path, err)
+		return nil
+	}
+
+	var entries []planEntry
+	seen := make(map[string]bool, len(targets))
+	for _, target := range targets {
+		destPath, err := config.destinationPath(target, file.path, file.name)
+		if err != nil {
+			log.Printf("Not planning %s for destination[%d]: %v", file.path, target.rule, err)
+			continue
+		}
+		if seen[destPath] {
+			continue
+		}
+		seen[destPath] = true
+
+		dest := config.Destinations[target.rule]
+		entries = append(entries, planEntry{
+			Source:      file.path,
+			Destination: destPath,
+			Rule:        target.rule,
+			RuleName:    config.ruleLabel(target.rule),
+			Action:      actionName(dest.Action),
+			Encrypt:     dest.Encrypt,
+			Size:        size,
+			ModTime:     modTime,
+			SHA256:      hash,
+		})
+	}
+
+	// As in processFile, a move to several destinations is done as copies
+	// followed by removing the source.
+	if len(entries) > 1 {
+		move := false
+		for i := range entries {
+			if entries[i].Action == actionMove {
+				entries[i].Action = actionCopy
+				move = true
+			}
+		}
+		entries[len(entries)-1].RemoveSource = move
+	}
+	return entries
+}
+
+// errDrifted reports that a planned source changed after the plan was made.
+var errDrifted = errors.New("source changed since the plan was made")
+
+// sourceState returns what a plan records about the source at path.
+func sourceState(path string) (size int64, modTime time.Time, hash string, err error) {
+	info, err := os.Lstat(path)
+	if err != nil {
+		return 0, time.Time{}, "", err
+	}
+	if info.IsDir() {
+		size, modTime = treeState(path)
+		return size, modTime, "", nil
+	}
+	hash, err = fileSHA256(path)
+	return info.Size(), info.ModTime(), hash, err
+}
+
+// checkDrift compares the source of entry with how it was when planned. A
+// different size or modification time is drift without reading the file;
+// otherwise the content hash decides, so a rewrite that keeps both is
+// caught too.
+func checkDrift(entry planEntry) error {
+	size, modTime, hash, err := sourceState(entry.Source)
+	if errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("%w: %s", errSourceGone, entry.Source)
+	}
+	if err != nil {
+		return err
+	}
+	if size != entry.Size || !modTime.Equal(entry.ModTime) {
+		return fmt.Errorf("%w: size or modification time differs", errDrifted)
+	}
+	if hash != entry.SHA256 {
+		return fmt.Errorf("%w: content differs", errDrifted)
+	}
+	return nil
+}
+
+// cmdApply carries out a plan written by "prefix plan". Each planned file
+// goes through the same filing as an organize pass, with on_conflict,
+// stable_for_seconds, scan_command, hash lists, pipelines and tags, to the
+// destinations in the plan. Files whose source has gone or changed since,
+// or whose rules have, are skipped. It exits 0 if every entry was applied
+// and 1 otherwise.
+func cmdApply(config *Config, args []string) int {
+	if len(args) != 1 {
+		fmt.Fprintln(os.Stderr, "usage: prefix apply <plan.json>")
+		return 2
+	}
+	data, err := os.ReadFile(args[0])
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to read plan: %v\n", err)
+		return 2
+	}
+	var p plan
+	if err := json.Unmarshal(data, &p); err != nil {
+		fmt.Fprintf(os.Stderr, "failed to parse plan: %v\n", err)
+		return 2
+	}
+
+	release, ok := lockForCommand("apply")
+	if !ok {
+		return 1
+	}
+	defer release()
+
+	// Entries for one source are next to each other, in filing order.
+	var sources [][]planEntry
+	for i, entry := range p.Entries {
+		if i > 0 && entry.Source == p.Entries[i-1].Source {
+			sources[len(sources)-1] = append(sources[len(sources)-1], entry)
+		} else {
+			sources = append(sources, []planEntry{entry})
+		}
+	}
+
+	pass := &passState{
+		config: config,
+		locks:  &pathLocks{locks: make(map[string]*sync.Mutex)},
+		result: &OrganizeResult{RuleMatches: make([]int, len(config.Destinations)), DryRun: config.DryRun},
+	}
+	code := 0
+	for _, entries := range sources {
+		if err := checkEntries(config, entries); err != nil {
+			for _, entry := range entries {
+				fmt.Printf("skipped: %s -> %s: %v\n", entry.Source, entry.Destination, err)
+			}
+			log.Printf("Not applying %s: %v", entries[0].Source, err)
+			code = 1
+			continue
+		}
+
+		targets := make([]routeTarget, len(entries))
+		for i, entry := range entries {
+			targets[i] = routeTarget{rule: entry.Rule, path: entry.Destination}
+		}
+		before := len(pass.result.Moves)
+		pass.fileTargets(candidate{path: entries[0].Source, name: filepath.Base(entries[0].Source)}, targets)
+		filed := pass.result.Moves[before:]
+		for _, move := range filed {
+			fmt.Printf("%s: %s -> %s\n", actionName(config.Destinations[move.Rule].Action), move.Source, move.Destination)
+		}
+		if len(filed) < len(entries) {
+			fmt.Printf("skipped: %s: %d of %d planned filings not applied, see the log\n", entries[0].Source, len(entries)-len(filed), len(entries))
+			code = 1
+		}
+	}
+
+	if config.ManifestPath != "" && !config.DryRun {
+		updateManifest(config, pass.result.Moves)
+	}
+	return code
+}
+
+// errRulesChanged reports that a planned rule no longer matches the config.
+var errRulesChanged = errors.New("rules changed since the plan was made")
+
+// checkEntries makes sure the planned entries for one source still describe
+// the current rules and that the source hasn't changed.
+func checkEntries(config *Config, entries []planEntry) error {
+	for _, entry := range entries {
+		if entry.Rule < 0 || entry.Rule >= len(config.Destinations) || config.ruleLabel(entry.Rule) != entry.RuleName || config.Destinations[entry.Rule].Encrypt != entry.Encrypt {
+			return fmt.Errorf("%w: %s", errRulesChanged, entry.RuleName)
+		}
+	}
+	return checkDrift(entries[0])
+}
@@ -0,0 +1,190 @@
+package organize
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestPlanSkipsIgnored(t *testing.T) {
+	dir := t.TempDir()
+	dump := filepath.Join(dir, "dump")
+	writeFile(t, filepath.Join(dump, "a_report.pdf"), "done", time.Time{})
+	writeFile(t, filepath.Join(dump, "a_video.mp4.crdownload"), "partial", time.Time{})
+	config := &Config{DumpDirectory: dump, Destinations: []Destination{{Prefix: "a_", Path: filepath.Join(dir, "out")}}}
+	if err := config.compile(); err != nil {
+		t.Fatal(err)
+	}
+
+	if entries := planFile(config, candidate{path: filepath.Join(dump, "a_video.mp4.crdownload"), name: "a_video.mp4.crdownload"}); len(entries) != 0 {
+		t.Errorf("planned an in-progress download: %+v", entries)
+	}
+	if entries := planFile(config, candidate{path: filepath.Join(dump, "a_report.pdf"), name: "a_report.pdf"}); len(entries) != 1 {
+		t.Errorf("planned %d entries for a_report.pdf, want 1", len(entries))
+	}
+	if n, err := countMatching(config); err != nil || n != 1 {
+		t.Errorf("countMatching = %d, %v, want 1", n, err)
+	}
+}
+
+func TestApply(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	dump := filepath.Join(home, "dump")
+	out := filepath.Join(home, "out")
+	writeFile(t, filepath.Join(dump, "a_report.pdf"), "new", time.Time{})
+	writeFile(t, filepath.Join(out, "a_report.pdf"), "old", time.Time{})
+	config := &Config{DumpDirectory: dump, Destinations: []Destination{{Prefix: "a_", Path: out, OnConflict: onConflictRename}}}
+	if err := config.compile(); err != nil {
+		t.Fatal(err)
+	}
+
+	entries := planFile(config, candidate{path: filepath.Join(dump, "a_report.pdf"), name: "a_report.pdf"})
+	data, err := json.Marshal(plan{Entries: entries})
+	if err != nil {
+		t.Fatal(err)
+	}
+	planPath := filepath.Join(home, "plan.json")
+	writeFile(t, planPath, string(data), time.Time{})
+
+	if err := os.MkdirAll(filepath.Join(home, ".config", "prefix"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	lock, err := acquireInstanceLock()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if code := cmdApply(config, []string{planPath}); code != 1 {
+		t.Errorf("apply with the instance lock held exited %d, want 1", code)
+	}
+	lock.release()
+
+	if code := cmdApply(config, []string{planPath}); code != 0 {
+		t.Fatalf("apply exited %d, want 0", code)
+	}
+	if got := readFile(t, filepath.Join(out, "a_report (1).pdf")); got != "new" {
+		t.Errorf("renamed file = %q, want %q", got, "new")
+	}
+	if got := readFile(t, filepath.Join(out, "a_report.pdf")); got != "old" {
+		t.Errorf("existing file = %q, want it untouched", got)
+	}
+}
+
+func TestApplyDrift(t *testing.T) {
+	mtime := time.Date(2024, 3, 15, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name   string
+		change func(t *testing.T, source string)
+		want   error
+	}{
+		{name: "unchanged", change: func(t *testing.T, source string) {}},
+		{name: "rewritten keeping size and mtime", want: errDrifted, change: func(t *testing.T, source string) {
+			writeFile(t, source, "NEW", mtime)
+		}},
+		{name: "grown", want: errDrifted, change: func(t *testing.T, source string) {
+			writeFile(t, source, "newer", mtime)
+		}},
+		{name: "touched", want: errDrifted, change: func(t *testing.T, source string) {
+			writeFile(t, source, "new", mtime.Add(time.Second))
+		}},
+		{name: "removed", want: errSourceGone, change: func(t *testing.T, source string) {
+			if err := os.Remove(source); err != nil {
+				t.Fatal(err)
+			}
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			home := t.TempDir()
+			t.Setenv("HOME", home)
+			if err := os.MkdirAll(filepath.Join(home, ".config", "prefix"), 0o755); err != nil {
+				t.Fatal(err)
+			}
+			dump := filepath.Join(home, "dump")
+			out := filepath.Join(home, "out")
+			source := filepath.Join(dump, "a_report.pdf")
+			writeFile(t, source, "new", mtime)
+			config := &Config{DumpDirectory: dump, Destinations: []Destination{{Prefix: "a_", Path: out}}}
+			if err := config.compile(); err != nil {
+				t.Fatal(err)
+			}
+			entries := planFile(config, candidate{path: source, name: "a_report.pdf"})
+			if len(entries) != 1 {
+				t.Fatalf("planned %d entries, want 1", len(entries))
+			}
+
+			tt.change(t, source)
+
+			if err := checkDrift(entries[0]); !errors.Is(err, tt.want) || (tt.want == nil) != (err == nil) {
+				t.Errorf("checkDrift = %v, want %v", err, tt.want)
+			}
+			data, err := json.Marshal(plan{Entries: entries})
+			if err != nil {
+				t.Fatal(err)
+			}
+			planPath := filepath.Join(home, "plan.json")
+			writeFile(t, planPath, string(data), time.Time{})
+			code := cmdApply(config, []string{planPath})
+			_, filedErr := os.Lstat(filepath.Join(out, "a_report.pdf"))
+			if tt.want == nil && (code != 0 || filedErr != nil) {
+				t.Errorf("apply exited %d, filed err %v, want the file filed", code, filedErr)
+			}
+			if tt.want != nil && (code != 1 || !os.IsNotExist(filedErr)) {
+				t.Errorf("apply exited %d, filed err %v, want exit 1 and nothing filed", code, filedErr)
+			}
+		})
+	}
+}
+
+func TestApplyDirectory(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	if err := os.MkdirAll(filepath.Join(home, ".config", "prefix"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	mtime := time.Date(2024, 3, 15, 12, 0, 0, 0, time.UTC)
+	dump := filepath.Join(home, "dump")
+	out := filepath.Join(home, "out")
+	source := filepath.Join(dump, "a_project")
+	writeFile(t, filepath.Join(source, "notes.txt"), "notes", mtime)
+	config := &Config{DumpDirectory: dump, Destinations: []Destination{{Prefix: "a_", Path: out, IncludeDirectories: true}}}
+	if err := config.compile(); err != nil {
+		t.Fatal(err)
+	}
+	entries := planFile(config, candidate{path: source, name: "a_project"})
+	if len(entries) != 1 {
+		t.Fatalf("planned %d entries, want 1", len(entries))
+	}
+	if err := checkDrift(entries[0]); err != nil {
+		t.Fatalf("checkDrift on an unchanged directory = %v", err)
+	}
+
+	data, err := json.Marshal(plan{Entries: entries})
+	if err != nil {
+		t.Fatal(err)
+	}
+	planPath := filepath.Join(home, "plan.json")
+	writeFile(t, planPath, string(data), time.Time{})
+	if code := cmdApply(config, []string{planPath}); code != 0 {
+		t.Fatalf("apply exited %d, want 0", code)
+	}
+	if got := readFile(t, filepath.Join(out, "a_project", "notes.txt")); got != "notes" {
+		t.Errorf("filed notes.txt = %q, want %q", got, "notes")
+	}
+
+	// A directory still being filled has drifted even though no file in it
+	// changed.
+	source = filepath.Join(dump, "a_growing")
+	writeFile(t, filepath.Join(source, "notes.txt"), "notes", mtime)
+	entries = planFile(config, candidate{path: source, name: "a_growing"})
+	if len(entries) != 1 {
+		t.Fatalf("planned %d entries, want 1", len(entries))
+	}
+	writeFile(t, filepath.Join(source, "more.txt"), "more", mtime.Add(time.Minute))
+	if err := checkDrift(entries[0]); !errors.Is(err, errDrifted) {
+		t.Errorf("checkDrift after adding a file = %v, want %v", err, errDrifted)
+	}
+}
@@ -0,0 +1,59 @@
+package organize
+
+import (
+	"fmt"
+	"log"
+	"os"
+)
+
+// Profile holds the machine-specific parts of a config. The active profile's
+// non-empty fields replace the top-level ones.
+type Profile struct {
+	DumpDirectory   string        `yaml:"dump_directory,omitempty"`
+	DumpDirectories []string      `yaml:"dump_directories,omitempty"`
+	DestinationRoot string        `yaml:"destination_root,omitempty"`
+	Destinations    []Destination `yaml:"destinations,omitempty"`
+}
+
+// activeProfile returns the profile named by --profile, or PREFIX_PROFILE
+// when the flag isn't given.
+func activeProfile() string {
+	if options.Profile != "" {
+		return options.Profile
+	}
+	return os.Getenv("PREFIX_PROFILE")
+}
+
+// applyProfile merges the named profile into the top-level config. With no
+// profiles defined, or no profile selected, the flat config is used as-is.
+func (c *Config) applyProfile(name string) error {
+	if len(c.Profiles) == 0 {
+		if name != "" {
+			log.Printf("Profile %q selected but the config defines no profiles, using top-level config", name)
+		}
+		return nil
+	}
+	if name == "" {
+		log.Println("No profile selected, using top-level config")
+		return nil
+	}
+
+	profile, ok := c.Profiles[name]
+	if !ok {
+		return fmt.Errorf("profile %q is not defined in the config", name)
+	}
+
+	log.Printf("Using profile: %s", name)
+	// A profile's dump directories replace all the top-level ones.
+	if profile.DumpDirectory != "" || len(profile.DumpDirectories) > 0 {
+		c.DumpDirectory = profile.DumpDirectory
+		c.DumpDirectories = profile.DumpDirectories
+	}
+	if profile.DestinationRoot != "" {
+		c.DestinationRoot = profile.DestinationRoot
+	}
+	if len(profile.Destinations) > 0 {
+		c.Destinations = profile.Destinations
+	}
+	return nil
+}
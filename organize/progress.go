@@ -0,0 +1,54 @@
+package organize
+
+import (
+	"io"
+	"log"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	defaultProgressThresholdMB = 100
+	progressInterval           = 5 * time.Second
+)
+
+// progressThreshold is the size in bytes from which copyFile logs its
+// progress. It is set from progress_threshold_mb by applySettings.
+var progressThreshold atomic.Int64
+
+func init() {
+	progressThreshold.Store(defaultProgressThresholdMB << 20)
+}
+
+// progressReader logs how much of a large copy is done every
+// progressInterval.
+type progressReader struct {
+	r     io.Reader
+	name  string
+	size  int64
+	done  int64
+	start time.Time
+	next  time.Time
+}
+
+// withProgress wraps r, the contents of the file name of the given size, in
+// a progressReader if the file is big enough to be worth reporting on.
+func withProgress(r io.Reader, name string, size int64) io.Reader {
+	threshold := progressThreshold.Load()
+	if threshold <= 0 || size < threshold {
+		return r
+	}
+	now := time.Now()
+	return &progressReader{r: r, name: name, size: size, start: now, next: now.Add(progressInterval)}
+}
+
+func (p *progressReader) Read(b []byte) (int, error) {
+	n, err := p.r.Read(b)
+	p.done += int64(n)
+	if now := time.Now(); now.After(p.next) {
+		p.next = now.Add(progressInterval)
+		log.Printf("Copying %s: %d%% (%s of %s, %v elapsed)", p.name, p.done*100/p.size,
+			formatBytes(p.done), formatBytes(p.size), now.Sub(p.start).Round(time.Second))
+	}
+	return n, err
+}
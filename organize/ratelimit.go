@@ -0,0 +1,69 @@
+package organize
+
+import (
+	"io"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// rateLimiter is a token bucket shared by all copies, so copy_rate_limit
+// caps the total rate however many workers are copying. The bucket holds
+// up to one second's worth of bytes.
+type rateLimiter struct {
+	rate float64 // bytes per second
+
+	mu     sync.Mutex
+	tokens float64
+	last   time.Time
+}
+
+func newRateLimiter(bytesPerSecond int64) *rateLimiter {
+	return &rateLimiter{rate: float64(bytesPerSecond), last: time.Now()}
+}
+
+// wait takes n bytes from the bucket, sleeping until they are available.
+// The bucket may go into debt, which later callers wait out.
+func (l *rateLimiter) wait(n int) {
+	l.mu.Lock()
+	now := time.Now()
+	l.tokens = min(l.rate, l.tokens+now.Sub(l.last).Seconds()*l.rate)
+	l.last = now
+	l.tokens -= float64(n)
+	debt := -l.tokens
+	l.mu.Unlock()
+
+	if debt > 0 {
+		time.Sleep(time.Duration(debt / l.rate * float64(time.Second)))
+	}
+}
+
+// copyLimiter, if set, limits the rate of copies. It is set from
+// copy_rate_limit by applySettings.
+var copyLimiter atomic.Pointer[rateLimiter]
+
+type rateLimitedReader struct {
+	r       io.Reader
+	limiter *rateLimiter
+}
+
+func (r *rateLimitedReader) Read(p []byte) (int, error) {
+	// Read in chunks no bigger than the bucket so sleeps stay short.
+	if limit := int(r.limiter.rate); len(p) > limit && limit > 0 {
+		p = p[:limit]
+	}
+	n, err := r.r.Read(p)
+	if n > 0 {
+		r.limiter.wait(n)
+	}
+	return n, err
+}
+
+// withRateLimit wraps r in the copy rate limit, if one is set.
+func withRateLimit(r io.Reader) io.Reader {
+	limiter := copyLimiter.Load()
+	if limiter == nil {
+		return r
+	}
+	return &rateLimitedReader{r: r, limiter: limiter}
+}
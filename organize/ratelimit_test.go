@@ -0,0 +1,40 @@
+package organize
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCopyRateLimit(t *testing.T) {
+	const rate = 1 << 20
+	copyLimiter.Store(newRateLimiter(rate))
+	t.Cleanup(func() { copyLimiter.Store(nil) })
+
+	dir := t.TempDir()
+	source := filepath.Join(dir, "big.bin")
+	writeFile(t, source, strings.Repeat("x", rate/2), time.Time{})
+
+	start := time.Now()
+	if err := copyFile(source, filepath.Join(dir, "limited.bin")); err != nil {
+		t.Fatal(err)
+	}
+	// The bucket starts empty, so half a second's worth of bytes takes
+	// about half a second.
+	if elapsed := time.Since(start); elapsed < 400*time.Millisecond || elapsed > 2*time.Second {
+		t.Errorf("copying %d bytes at %d bytes/s took %v, want about 500ms", rate/2, rate, elapsed)
+	}
+	if got := readFile(t, filepath.Join(dir, "limited.bin")); len(got) != rate/2 {
+		t.Errorf("copied %d bytes, want %d", len(got), rate/2)
+	}
+
+	copyLimiter.Store(nil)
+	start = time.Now()
+	if err := copyFile(source, filepath.Join(dir, "unlimited.bin")); err != nil {
+		t.Fatal(err)
+	}
+	if elapsed := time.Since(start); elapsed > 200*time.Millisecond {
+		t.Errorf("unlimited copy took %v", elapsed)
+	}
+}
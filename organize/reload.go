@@ -0,0 +1,183 @@
+package organize
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// reloadDelay debounces config file events, since editors save in several
+// steps.
+const reloadDelay = 500 * time.Millisecond
+
+// applySettings copies the settings read by package-level state, rather
+// than through the config, into place.
+func applySettings(config *Config) {
+	preserveOwnership.Store(config.PreserveOwnership)
+	if config.copyRateLimit > 0 {
+		copyLimiter.Store(newRateLimiter(config.copyRateLimit))
+	} else {
+		copyLimiter.Store(nil)
+	}
+	if config.ProgressThresholdMB != 0 {
+		progressThreshold.Store(int64(config.ProgressThresholdMB * (1 << 20)))
+	} else {
+		progressThreshold.Store(defaultProgressThresholdMB << 20)
+	}
+}
+
+// reloadConfig loads and validates the config the way Run does at start,
+// including the --rule and --dry-run flags.
+func reloadConfig() (*Config, error) {
+	config, err := LoadConfig(false, activeProfile())
+	if err != nil {
+		return nil, err
+	}
+	if err := config.addCLIRules(options.Rules); err != nil {
+		return nil, fmt.Errorf("invalid --rule: %w", err)
+	}
+	if options.DryRun {
+		config.DryRun = true
+	}
+	if problems := config.Validate(); len(problems) > 0 {
+		return nil, fmt.Errorf("config has %d problem(s); run \"prefix validate\" to list them: %w", len(problems), errors.Join(problems...))
+	}
+	return config, nil
+}
+
+// Reload re-reads the config and swaps it in for the watcher and every
+// later organize pass. If the new config doesn't load or validate, the
+// current one stays in use. Settings only read at start, such as
+// dump_directory or schedule, still need a restart.
+func (o *Organizer) Reload() error {
+	config, err := reloadConfig()
+	if err != nil {
+		log.Printf("Config reload failed, keeping the current config: %v", err)
+		return err
+	}
+
+	old := o.config.Swap(config)
+	o.files.config.Store(config)
+	applySettings(config)
+	o.watchIncludes(config)
+	for _, option := range restartOptions(old, config) {
+		log.Printf("Warning: %s changed; restart prefix to apply it", option)
+	}
+	log.Printf("Config reloaded: %d destination rules", len(config.Destinations))
+	return nil
+}
+
+// restartOptions lists the options that differ between old and config but
+// only take effect at start.
+func restartOptions(old, config *Config) []string {
+	var changed []string
+	if !slices.Equal(old.dumpDirs(), config.dumpDirs()) {
+		changed = append(changed, "dump_directory")
+	}
+	if old.Recursive != config.Recursive {
+		changed = append(changed, "recursive")
+	}
+	if old.SocketPath != config.SocketPath {
+		changed = append(changed, "socket_path")
+	}
+	if old.Schedule != config.Schedule {
+		changed = append(changed, "schedule")
+	}
+	if old.SummaryIntervalMinutes != config.SummaryIntervalMinutes {
+		changed = append(changed, "summary_interval_minutes")
+	}
+	if old.LogTimestampFormat != config.LogTimestampFormat {
+		changed = append(changed, "log_timestamp_format")
+	}
+	return changed
+}
+
+// watchConfig adds the config directory, and its rules.d if there is one,
+// to the watcher so saving the config reloads it. Failing to watch them
+// only costs the automatic reload; SIGHUP still works.
+func (o *Organizer) watchConfig() {
+	dir, err := configDir()
+	if err != nil {
+		log.Printf("Not watching the config for changes: %v", err)
+		return
+	}
+	if err := o.watcher.Add(dir); err != nil {
+		log.Printf("Not watching the config for changes: %v", err)
+		return
+	}
+	o.configDir = dir
+	o.watcher.Add(filepath.Join(dir, "rules.d"))
+	o.watchIncludes(o.config.Load())
+}
+
+// watchIncludes watches the directories of config's patterns_file and hash
+// list files, so editing one reloads the config too. Directories are
+// watched rather than the files, since editors often save by replacing the
+// file.
+func (o *Organizer) watchIncludes(config *Config) {
+	if o.watcher == nil {
+		return
+	}
+	o.reloadMu.Lock()
+	defer o.reloadMu.Unlock()
+	o.includes = make(map[string]bool, len(config.includes))
+	if o.includeDirs == nil {
+		o.includeDirs = make(map[string]bool)
+	}
+	for _, path := range config.includes {
+		o.includes[path] = true
+		dir := filepath.Dir(path)
+		if o.includeDirs[dir] || dir == o.configDir || slices.Contains(o.watcher.WatchList(), dir) {
+			continue
+		}
+		if err := o.watcher.Add(dir); err != nil {
+			log.Printf("Not watching %s for changes: %v", path, err)
+			continue
+		}
+		o.includeDirs[dir] = true
+	}
+}
+
+// isConfigEvent reports whether event is in the config directory, or is
+// for a file the config includes. Those are never organized; a change to
+// the config file, a rule file or an included file schedules a reload, and
+// everything else there (the log, state files) is ignored. Events in
+// directories watched only for included files are ignored likewise.
+func (o *Organizer) isConfigEvent(event fsnotify.Event) bool {
+	dir, name := filepath.Split(event.Name)
+	dir = filepath.Clean(dir)
+
+	reload, ignore := false, false
+	if o.configDir != "" {
+		rulesDir := filepath.Join(o.configDir, "rules.d")
+		reload = dir == o.configDir && name == "prefix.yaml" || dir == rulesDir && strings.HasSuffix(name, ".yaml")
+		ignore = dir == o.configDir || dir == rulesDir
+	}
+	o.reloadMu.Lock()
+	reload = reload || o.includes[filepath.Clean(event.Name)]
+	ignore = ignore || o.includeDirs[dir]
+	o.reloadMu.Unlock()
+	if !reload {
+		return ignore
+	}
+
+	if event.Has(fsnotify.Chmod) && !event.Has(fsnotify.Write) {
+		return true
+	}
+	o.reloadMu.Lock()
+	defer o.reloadMu.Unlock()
+	if o.reloadTimer != nil {
+		o.reloadTimer.Stop()
+	}
+	o.reloadTimer = clock.AfterFunc(reloadDelay, func() {
+		log.Printf("Config changed (%s), reloading...", event.Name)
+		o.Reload()
+	})
+	return true
+}
@@ -0,0 +1,54 @@
+package organize
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+func TestIncludedFilesReload(t *testing.T) {
+	useFakeClock(t, time.Now())
+	dir := t.TempDir()
+	lists := filepath.Join(dir, "lists")
+	writeFile(t, filepath.Join(lists, "patterns.txt"), "a_\n", time.Time{})
+	writeFile(t, filepath.Join(lists, "deny.txt"), "", time.Time{})
+	writeFile(t, filepath.Join(dir, "prefix.yaml"), "dump_directory: "+filepath.Join(dir, "dump")+"\n"+
+		"hash_denylist: lists/deny.txt\n"+
+		"destinations:\n  - path: "+filepath.Join(dir, "out")+"\n    patterns_file: lists/patterns.txt\n", time.Time{})
+	config, err := loadConfigFile(filepath.Join(dir, "prefix.yaml"), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	o := NewOrganizer(config)
+	if o.watcher, err = fsnotify.NewWatcher(); err != nil {
+		t.Fatal(err)
+	}
+	defer o.watcher.Close()
+	o.watchIncludes(config)
+
+	tests := []struct {
+		name       string
+		ignored    bool
+		wantReload bool
+	}{
+		{"patterns.txt", true, true},
+		{"deny.txt", true, true},
+		{"notes.txt", true, false},
+	}
+	for _, tt := range tests {
+		o.reloadTimer = nil
+		event := fsnotify.Event{Name: filepath.Join(lists, tt.name), Op: fsnotify.Write}
+		if got := o.isConfigEvent(event); got != tt.ignored {
+			t.Errorf("isConfigEvent(%s) = %v, want %v", tt.name, got, tt.ignored)
+		}
+		if reload := o.reloadTimer != nil; reload != tt.wantReload {
+			t.Errorf("reload scheduled for %s = %v, want %v", tt.name, reload, tt.wantReload)
+		}
+	}
+	if o.isConfigEvent(fsnotify.Event{Name: filepath.Join(dir, "dump", "a_file.txt"), Op: fsnotify.Create}) {
+		t.Error("event in the dump directory treated as a config event")
+	}
+}
@@ -0,0 +1,88 @@
+package organize
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// strftimeLayouts maps strftime conversions to Go time layouts.
+var strftimeLayouts = map[byte]string{
+	'Y': "2006",
+	'y': "06",
+	'm': "01",
+	'd': "02",
+	'H': "15",
+	'I': "03",
+	'M': "04",
+	'S': "05",
+	'p': "PM",
+	'b': "Jan",
+	'B': "January",
+	'a': "Mon",
+	'A': "Monday",
+	'Z': "MST",
+	'z': "-0700",
+}
+
+// strftime formats t using the strftime-style conversions in pattern, such
+// as %Y-%m-%d. %j is the day of the year and %% a literal percent sign;
+// unknown conversions are kept as written.
+func strftime(pattern string, t time.Time) string {
+	var b strings.Builder
+	for i := 0; i < len(pattern); i++ {
+		if pattern[i] != '%' || i+1 == len(pattern) {
+			b.WriteByte(pattern[i])
+			continue
+		}
+		i++
+		switch c := pattern[i]; c {
+		case '%':
+			b.WriteByte('%')
+		case 'j':
+			fmt.Fprintf(&b, "%03d", t.YearDay())
+		default:
+			if layout, ok := strftimeLayouts[c]; ok {
+				b.WriteString(t.Format(layout))
+			} else {
+				b.WriteByte('%')
+				b.WriteByte(c)
+			}
+		}
+	}
+	return b.String()
+}
+
+// nameTokens returns the replacements for the {name}, {stem} and {ext}
+// placeholders shared by rename_pattern, path and pipeline commands: the
+// whole file name, the name without its extension, and the extension
+// including its dot.
+func nameTokens(filename string) []string {
+	ext := filepath.Ext(filename)
+	return []string{
+		"{name}", filename,
+		"{stem}", strings.TrimSuffix(filename, ext),
+		"{ext}", ext,
+	}
+}
+
+// renamedName builds the name a file is filed under from dest.RenamePattern.
+// Date conversions are filled in from the file's timestamp (see
+// time_source), then {name}, {stem} and {ext} from the original name, so
+// "{stem}-%Y-%m-%d{ext}" turns app.log into app-2024-03-15.log.
+func renamedName(dest Destination, timeSource, path, filename string) (string, error) {
+	info, err := os.Lstat(path)
+	if err != nil {
+		return "", err
+	}
+
+	name := strftime(dest.RenamePattern, fileTime(path, info, timeSource))
+	name = strings.NewReplacer(nameTokens(filename)...).Replace(name)
+
+	if !validSegment(name) {
+		return "", fmt.Errorf("rename_pattern %q gives invalid name %q for %s", dest.RenamePattern, name, filename)
+	}
+	return name, nil
+}
@@ -0,0 +1,44 @@
+package organize
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestRenamedName(t *testing.T) {
+	mtime := time.Date(2024, 3, 15, 9, 30, 0, 0, time.Local)
+	tests := []struct {
+		pattern  string
+		filename string
+		want     string
+	}{
+		{"{stem}-%Y-%m-%d{ext}", "app.log", "app-2024-03-15.log"},
+		{"%Y%m%d-{name}", "report.pdf", "20240315-report.pdf"},
+		{"{stem}{ext}", "backup.tar.gz", "backup.tar.gz"},
+		{"%H%M-{stem}", "README", "0930-README"},
+		{"100%%-{name}", "a.txt", "100%-a.txt"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.pattern, func(t *testing.T) {
+			file := filepath.Join(t.TempDir(), tt.filename)
+			writeFile(t, file, "", mtime)
+
+			got, err := renamedName(Destination{RenamePattern: tt.pattern}, timeSourceMtime, file, tt.filename)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("renamedName(%q, %q) = %q, want %q", tt.pattern, tt.filename, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRenamedNameInvalid(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "a.txt")
+	writeFile(t, file, "", time.Time{})
+	if _, err := renamedName(Destination{RenamePattern: "../{name}"}, timeSourceMtime, file, "a.txt"); err == nil {
+		t.Error("rename_pattern escaping the destination was accepted")
+	}
+}
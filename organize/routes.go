@@ -0,0 +1,85 @@
+package organize
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+
+	"gopkg.in/yaml.v3"
+)
+
+// route is one prefix -> subfolder entry of a destination's routes.
+type route struct {
+	Prefix    string
+	Subfolder string
+}
+
+// routeMap is a routes mapping kept in the order it was written, since the
+// order decides which of two overlapping prefixes wins.
+type routeMap []route
+
+func (m *routeMap) UnmarshalYAML(node *yaml.Node) error {
+	if node.Kind != yaml.MappingNode {
+		return fmt.Errorf("line %d: routes must be a mapping of prefix to subfolder", node.Line)
+	}
+	seen := make(map[string]bool)
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		var r route
+		if err := node.Content[i].Decode(&r.Prefix); err != nil {
+			return err
+		}
+		if err := node.Content[i+1].Decode(&r.Subfolder); err != nil {
+			return err
+		}
+		if seen[r.Prefix] {
+			return fmt.Errorf("line %d: routes lists prefix %q more than once", node.Content[i].Line, r.Prefix)
+		}
+		seen[r.Prefix] = true
+		*m = append(*m, r)
+	}
+	return nil
+}
+
+// expandRoutes replaces each destination that has routes with one
+// destination per route, in order, matching the route's prefix and filing
+// into the route's subfolder of the destination's path. The other fields
+// are shared by all of them.
+func (c *Config) expandRoutes() error {
+	var errs []error
+	expanded := make([]Destination, 0, len(c.Destinations))
+	for i, dest := range c.Destinations {
+		if len(dest.Routes) == 0 {
+			expanded = append(expanded, dest)
+			continue
+		}
+		if dest.Path == "" {
+			errs = append(errs, fmt.Errorf("destination[%d]: routes need path as their common root", i))
+			continue
+		}
+		if dest.Prefix != "" {
+			errs = append(errs, fmt.Errorf("destination[%d]: prefix can't be combined with routes", i))
+			continue
+		}
+
+		for _, r := range dest.Routes {
+			if r.Prefix == "" || r.Subfolder == "" {
+				errs = append(errs, fmt.Errorf("destination[%d]: routes can't have an empty prefix or subfolder", i))
+				continue
+			}
+			d := dest
+			d.Routes = nil
+			d.Prefix = r.Prefix
+			d.Path = filepath.Join(dest.Path, r.Subfolder)
+			if dest.Name != "" {
+				d.Name = dest.Name + "." + r.Prefix
+				d.group = dest.Name
+			}
+			expanded = append(expanded, d)
+		}
+	}
+	if len(errs) > 0 {
+		return errors.Join(errs...)
+	}
+	c.Destinations = expanded
+	return nil
+}
@@ -0,0 +1,73 @@
+package organize
+
+import (
+	"fmt"
+	"strings"
+)
+
+// RuleFlags collects repeated --rule flags.
+type RuleFlags []Destination
+
+func (r *RuleFlags) String() string {
+	return fmt.Sprintf("%d rule(s)", len(*r))
+}
+
+func (r *RuleFlags) Set(spec string) error {
+	dest, err := parseRuleSpec(spec)
+	if err != nil {
+		return err
+	}
+	*r = append(*r, dest)
+	return nil
+}
+
+// parseRuleSpec parses a --rule spec of comma-separated key=value pairs,
+// e.g. "prefix=IMG_,dest=/tmp/out". Keys are prefix, suffix, dest (or path),
+// action, magic and name.
+func parseRuleSpec(spec string) (Destination, error) {
+	var dest Destination
+	for _, field := range strings.Split(spec, ",") {
+		key, value, ok := strings.Cut(field, "=")
+		key = strings.TrimSpace(key)
+		if !ok || key == "" {
+			return Destination{}, fmt.Errorf("invalid rule %q: expected key=value, got %q", spec, field)
+		}
+		switch key {
+		case "prefix":
+			dest.Prefix = value
+		case "suffix":
+			dest.Suffix = value
+		case "dest", "path":
+			dest.Path = value
+		case "action":
+			dest.Action = value
+		case "magic":
+			dest.Magic = value
+		case "name":
+			dest.Name = value
+		default:
+			return Destination{}, fmt.Errorf("invalid rule %q: unknown key %q", spec, key)
+		}
+	}
+
+	if dest.Path == "" {
+		return Destination{}, fmt.Errorf("invalid rule %q: dest is required", spec)
+	}
+	if dest.Prefix == "" && dest.Suffix == "" && dest.Magic == "" {
+		return Destination{}, fmt.Errorf("invalid rule %q: needs prefix, suffix or magic", spec)
+	}
+	if !validAction(dest.Action) {
+		return Destination{}, fmt.Errorf("invalid rule %q: action must be one of move, copy, symlink, hardlink", spec)
+	}
+	return dest, nil
+}
+
+// addCLIRules puts the --rule destinations ahead of the configured ones, so
+// they take priority for this run.
+func (c *Config) addCLIRules(rules []Destination) error {
+	if len(rules) == 0 {
+		return nil
+	}
+	c.Destinations = append(append([]Destination{}, rules...), c.Destinations...)
+	return c.compile()
+}
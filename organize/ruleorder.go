@@ -0,0 +1,71 @@
+package organize
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+)
+
+// applyRuleOrder moves the destinations named in rule_order to the front, in
+// that order, so they take priority when several destinations match. The
+// name of a routes or patterns_file block stands for all the destinations
+// it was expanded into. Unnamed and unlisted destinations follow in config
+// order.
+func (c *Config) applyRuleOrder() error {
+	var errs []error
+	byName := make(map[string][]int, len(c.Destinations))
+	for i, dest := range c.Destinations {
+		if dest.group == "" {
+			continue
+		}
+		// A block's destinations are consecutive, so a group seen again
+		// after a gap is a second block with the same name.
+		if rules := byName[dest.group]; len(rules) > 0 && rules[len(rules)-1] != i-1 {
+			errs = append(errs, fmt.Errorf("destination[%d]: duplicate name %q", i, dest.group))
+			continue
+		}
+		byName[dest.group] = append(byName[dest.group], i)
+	}
+	for i, dest := range c.Destinations {
+		if dest.Name == "" {
+			continue
+		}
+		if _, dup := byName[dest.Name]; dup {
+			errs = append(errs, fmt.Errorf("destination[%d]: duplicate name %q", i, dest.Name))
+			continue
+		}
+		byName[dest.Name] = []int{i}
+	}
+	if len(c.RuleOrder) == 0 {
+		return errors.Join(errs...)
+	}
+
+	ordered := make([]Destination, 0, len(c.Destinations))
+	placed := make([]bool, len(c.Destinations))
+	for _, name := range c.RuleOrder {
+		rules, ok := byName[name]
+		if !ok {
+			errs = append(errs, fmt.Errorf("rule_order: no destination named %q", name))
+			continue
+		}
+		if slices.ContainsFunc(rules, func(i int) bool { return placed[i] }) {
+			errs = append(errs, fmt.Errorf("rule_order: %q is listed more than once", name))
+			continue
+		}
+		for _, i := range rules {
+			placed[i] = true
+			ordered = append(ordered, c.Destinations[i])
+		}
+	}
+	if len(errs) > 0 {
+		return errors.Join(errs...)
+	}
+
+	for i, dest := range c.Destinations {
+		if !placed[i] {
+			ordered = append(ordered, dest)
+		}
+	}
+	c.Destinations = ordered
+	return nil
+}
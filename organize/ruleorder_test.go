@@ -0,0 +1,80 @@
+package organize
+
+import (
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRuleOrderExpandedBlocks(t *testing.T) {
+	dir := t.TempDir()
+	dump := filepath.Join(dir, "dump")
+	writeFile(t, filepath.Join(dir, "docs.txt"), "prefix:INV_\nsuffix:.pdf\n", time.Time{})
+
+	tests := []struct {
+		name      string
+		ruleOrder string
+		want      []string
+		wantErr   string
+	}{
+		{name: "routes block", ruleOrder: "[media]", want: []string{"media.IMG_", "media.VID_", "all", "docs.INV_", "docs..pdf"}},
+		{name: "patterns_file block", ruleOrder: "[docs, media]", want: []string{"docs.INV_", "docs..pdf", "media.IMG_", "media.VID_", "all"}},
+		{name: "one expanded entry", ruleOrder: "[media.VID_]", want: []string{"media.VID_", "all", "media.IMG_", "docs.INV_", "docs..pdf"}},
+		{name: "block and its entry", ruleOrder: "[media.VID_, media]", wantErr: `"media" is listed more than once`},
+		{name: "unknown", ruleOrder: "[photos]", wantErr: `no destination named "photos"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(dir, "prefix.yaml")
+			writeFile(t, path, `dump_directory: `+dump+`
+rule_order: `+tt.ruleOrder+`
+destinations:
+  - name: all
+    prefix: ""
+    suffix: .txt
+    path: `+filepath.Join(dir, "all")+`
+  - name: media
+    path: `+filepath.Join(dir, "media")+`
+    routes: {IMG_: Photos, VID_: Videos}
+  - name: docs
+    path: `+filepath.Join(dir, "docs")+`
+    patterns_file: docs.txt
+`, time.Time{})
+
+			config, err := loadConfigFile(path, "")
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("loadConfigFile error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			var names []string
+			for _, dest := range config.Destinations {
+				names = append(names, dest.Name)
+			}
+			if !slices.Equal(names, tt.want) {
+				t.Errorf("order = %q, want %q", names, tt.want)
+			}
+		})
+	}
+}
+
+func TestRuleOrderDuplicateBlockName(t *testing.T) {
+	config := &Config{Destinations: []Destination{
+		{Name: "media.IMG_", group: "media"},
+		{Name: "other"},
+		{Name: "media.VID_", group: "media"},
+	}}
+	if err := config.applyRuleOrder(); err == nil || !strings.Contains(err.Error(), `duplicate name "media"`) {
+		t.Errorf("applyRuleOrder error = %v, want a duplicate name", err)
+	}
+	config = &Config{Destinations: []Destination{{Name: "media.IMG_", group: "media"}, {Name: "media"}}}
+	if err := config.applyRuleOrder(); err == nil || !strings.Contains(err.Error(), `duplicate name "media"`) {
+		t.Errorf("applyRuleOrder error = %v, want a duplicate name", err)
+	}
+}
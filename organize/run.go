@@ -0,0 +1,260 @@
+package organize
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// Options are the command-line settings of a run. The zero value is a run
+// with no flags, which is also how the package behaves when used as a
+// library without Run.
+type Options struct {
+	// Args are the subcommand and its arguments, if any.
+	Args []string
+
+	Profile         string
+	Rules           RuleFlags
+	DryRun          bool
+	Seed            int64
+	SummaryInterval time.Duration
+	IgnoreLock      bool
+	AssumeYes       bool
+	LogStderr       bool
+
+	NoCreateConfig bool
+	Diagnose       bool
+	WatchOnly      bool
+	Once           bool
+	OutputFormat   string
+	ReportUnused   bool
+	StrictUnused   bool
+	Force          bool
+}
+
+// options are the settings of the current Run, read by the parts of the
+// package that a flag can override.
+var options Options
+
+// openLogFile opens ~/.config/prefix/app.log for appending. If that fails,
+// e.g. with a read-only home directory or a full disk, it warns and returns
+// stderr so the organizer can still run. --log-stderr always uses stderr.
+func openLogFile() *os.File {
+	if options.LogStderr {
+		return os.Stderr
+	}
+
+	home, err := os.UserHomeDir()
+	if err != nil {
+		log.Printf("Warning: could not get home directory, logging to stderr: %v", err)
+		return os.Stderr
+	}
+
+	logFilePath := filepath.Join(home, ".config", "prefix", "app.log")
+	logFile, err := os.OpenFile(logFilePath, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o666)
+	if err != nil {
+		log.Printf("Warning: failed to open log file, logging to stderr: %v", err)
+		return os.Stderr
+	}
+	return logFile
+}
+
+// Run is the prefix command: it runs the subcommand in opts.Args, or the
+// startup pass and then the organizer until it is signalled to stop. It
+// returns the process exit code.
+func Run(opts Options) int {
+	options = opts
+	if options.OutputFormat == "" {
+		options.OutputFormat = outputText
+	}
+	args := options.Args
+
+	logFile := openLogFile()
+	defer logFile.Close()
+
+	log.SetOutput(logFile)
+
+	log.SetFlags(log.Ldate | log.Ltime | log.Lshortfile)
+
+	log.Println("File organizer starting...")
+	if len(args) > 0 && args[0] == "migrate" {
+		return cmdMigrate(args[1:])
+	}
+	config, err := LoadConfig(!options.NoCreateConfig, activeProfile())
+	if err != nil {
+		if len(args) > 0 && args[0] == "validate" {
+			return cmdValidate(splitErrors(err))
+		}
+		log.Fatalf("Failed to load config: %v", err)
+	}
+
+	if err := config.addCLIRules(options.Rules); err != nil {
+		log.Fatalf("Invalid --rule: %v", err)
+	}
+	if len(options.Rules) > 0 {
+		log.Printf("Added %d rule(s) from --rule", len(options.Rules))
+	}
+	if options.DryRun {
+		config.DryRun = true
+	}
+	if config.DryRun {
+		log.Println("Dry run: no files will be changed")
+	}
+
+	problems := config.Validate()
+	if len(args) > 0 && args[0] == "validate" {
+		return cmdValidate(problems)
+	}
+	if len(problems) > 0 {
+		for _, problem := range problems {
+			log.Printf("Invalid config: %v", problem)
+		}
+		log.Fatalf("config has %d problem(s); run \"prefix validate\" to list them", len(problems))
+	}
+	setLogTimestampFormat(logFile, config.LogTimestampFormat)
+
+	applySettings(config)
+
+	seed := runSeed(config)
+	rng.seed(seed)
+	log.Printf("Random seed: %d", seed)
+
+	// Subcommands only need the rules, not the dump directory.
+	if len(args) > 0 {
+		return runCommand(config, args)
+	}
+
+	for _, dir := range config.dumpDirs() {
+		if _, err := os.Stat(dir); os.IsNotExist(err) {
+			log.Fatalf("Dump directory does not exist: %s", dir)
+		}
+	}
+
+	log.Printf("Dump directory: %s", strings.Join(config.dumpDirs(), ", "))
+	log.Printf("Processing %d destination rules", len(config.Destinations))
+
+	if options.WatchOnly && options.ReportUnused {
+		log.Fatalf("--watch-only and --report-unused cannot be combined")
+	}
+	if options.WatchOnly && options.Once {
+		log.Fatalf("--watch-only and --once cannot be combined")
+	}
+	if options.OutputFormat != outputText && options.OutputFormat != outputJSON {
+		log.Fatalf("--output must be %q or %q", outputText, outputJSON)
+	}
+	if options.WatchOnly && options.Force {
+		log.Fatalf("--force only applies to the startup pass and cannot be combined with --watch-only")
+	}
+
+	if options.IgnoreLock {
+		log.Println("Not taking the instance lock (--ignore-lock)")
+	} else {
+		lock, err := acquireInstanceLock()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "prefix: %v; stop it first, or pass --ignore-lock\n", err)
+			log.Fatalf("Refusing to start: %v", err)
+		}
+		defer lock.release()
+	}
+
+	if options.Diagnose {
+		diagnoseOverlap(config)
+	}
+
+	if options.WatchOnly {
+		log.Println("Watch-only mode: leaving existing files in place")
+	} else {
+		if !confirmStartupPass(config) {
+			log.Println("Startup pass not confirmed, exiting")
+			fmt.Fprintln(os.Stderr, "Aborted.")
+			return 1
+		}
+		log.Println("Organizing existing files...")
+		if options.Force {
+			log.Println("Overwriting existing destinations for this pass (--force)")
+			forceOverwrite.Store(true)
+		}
+		result, err := organizeWith(config, config.initialWorkers())
+		forceOverwrite.Store(false)
+		if err != nil {
+			log.Printf("Error organizing initial files: %v", err)
+		}
+
+		if options.Once {
+			if result == nil {
+				log.Fatalf("Organize pass failed: %v", err)
+			}
+			if err := printResult(result, options.OutputFormat); err != nil {
+				log.Fatalf("failed to write summary: %v", err)
+			}
+			if failed := result.Errors + result.InsufficientSpace; failed > 0 {
+				log.Printf("%d file(s) could not be filed", failed)
+				return 1
+			}
+			return 0
+		}
+
+		if options.ReportUnused {
+			if result == nil {
+				log.Fatalf("Cannot report unused destinations: %v", err)
+			}
+			unused := printUnused(config, result)
+			if options.StrictUnused && unused > 0 {
+				return 1
+			}
+			return 0
+		}
+	}
+
+	organizer := NewOrganizer(config)
+	if err := organizer.Start(context.Background()); err != nil {
+		log.Fatalf("Failed to start organizer: %v", err)
+	}
+
+	reloadChan := make(chan os.Signal, 1)
+	signal.Notify(reloadChan, syscall.SIGHUP)
+	go func() {
+		for range reloadChan {
+			log.Println("Received SIGHUP, reloading config...")
+			organizer.Reload()
+		}
+	}()
+
+	pauseChan := make(chan os.Signal, 1)
+	signal.Notify(pauseChan, syscall.SIGUSR1, syscall.SIGUSR2)
+	go func() {
+		for sig := range pauseChan {
+			switch sig {
+			case syscall.SIGUSR1:
+				organizer.Pause()
+			case syscall.SIGUSR2:
+				organizer.Resume()
+			}
+		}
+	}()
+
+	sigChan := make(chan os.Signal, 1)
+	signal.Notify(sigChan, os.Interrupt, syscall.SIGTERM)
+	drainChan := make(chan os.Signal, 1)
+	signal.Notify(drainChan, syscall.SIGQUIT)
+
+	log.Println("File organizer started. Press Ctrl+C to stop.")
+
+	select {
+	case sig := <-sigChan:
+		log.Printf("Received signal: %v. Shutting down gracefully...", sig)
+		organizer.Stop()
+	case sig := <-drainChan:
+		log.Printf("Received signal: %v. Draining before exit...", sig)
+		organizer.Drain()
+	}
+
+	log.Println("File organizer stopped")
+	return 0
+}
@@ -0,0 +1,58 @@
+package organize
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os/exec"
+	"path/filepath"
+	"time"
+)
+
+const defaultScanTimeout = 60 * time.Second
+
+// errInfected reports that scan_command rejected a file.
+var errInfected = errors.New("scan reported file as infected")
+
+// scanFile runs config.ScanCommand with path appended as its last argument.
+// A non-zero exit status returns errInfected; a command that cannot be run
+// or times out returns another error, and the file should be left alone.
+func scanFile(config *Config, path string) error {
+	timeout := defaultScanTimeout
+	if config.ScanTimeoutSeconds > 0 {
+		timeout = seconds(config.ScanTimeoutSeconds)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	args := append(append([]string{}, config.ScanCommand[1:]...), path)
+	output, err := exec.CommandContext(ctx, config.ScanCommand[0], args...).CombinedOutput()
+	if ctx.Err() != nil {
+		return fmt.Errorf("scan of %s timed out after %v", path, timeout)
+	}
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		if len(output) > 0 {
+			log.Printf("Scan output for %s: %s", path, output)
+		}
+		return fmt.Errorf("%w: %s (exit status %d)", errInfected, path, exitErr.ExitCode())
+	}
+	if err != nil {
+		return fmt.Errorf("failed to run scan command: %w", err)
+	}
+	return nil
+}
+
+// quarantine moves the file at path into the quarantine directory.
+func quarantine(config *Config, path string) (string, error) {
+	destPath, err := safeJoin(config.QuarantineDirectory, filepath.Base(path))
+	if err != nil {
+		return "", err
+	}
+	if err := moveFile(path, destPath); err != nil {
+		log.Printf("failed to quarantine file: %v", err)
+		return "", fmt.Errorf("failed to quarantine file: %w", err)
+	}
+	return destPath, nil
+}
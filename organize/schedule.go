@@ -0,0 +1,25 @@
+package organize
+
+import (
+	"fmt"
+	"log"
+
+	"github.com/robfig/cron/v3"
+)
+
+// startSchedule runs an organize pass on the cron schedule in
+// config.Schedule, e.g. "0 2 * * *" for every night at 2am. Stop the
+// returned scheduler on shutdown.
+func startSchedule(config *Config, organizer *fileOrganizer) (*cron.Cron, error) {
+	scheduler := cron.New()
+	_, err := scheduler.AddFunc(config.Schedule, func() {
+		log.Println("Scheduled organize pass starting...")
+		organizer.organize(config)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("invalid schedule %q: %w", config.Schedule, err)
+	}
+
+	scheduler.Start()
+	return scheduler, nil
+}
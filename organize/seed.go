@@ -0,0 +1,47 @@
+package organize
+
+import (
+	"math/rand"
+	"sync"
+	"time"
+)
+
+// lockedRand is a *rand.Rand safe for use by the organize workers.
+type lockedRand struct {
+	mu  sync.Mutex
+	rnd *rand.Rand
+}
+
+// rng is the source for all randomized behavior, so that a fixed --seed
+// makes a run reproducible. It is seeded in Run.
+var rng = newLockedRand(time.Now().UnixNano())
+
+func newLockedRand(seed int64) *lockedRand {
+	return &lockedRand{rnd: rand.New(rand.NewSource(seed))}
+}
+
+// seed reseeds the source.
+func (r *lockedRand) seed(seed int64) {
+	r.mu.Lock()
+	r.rnd.Seed(seed)
+	r.mu.Unlock()
+}
+
+// Float64 returns a pseudo-random number in [0.0, 1.0).
+func (r *lockedRand) Float64() float64 {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.rnd.Float64()
+}
+
+// runSeed picks the seed for this run: --seed, then the config's seed, and
+// otherwise the current time.
+func runSeed(config *Config) int64 {
+	if options.Seed != 0 {
+		return options.Seed
+	}
+	if config.Seed != 0 {
+		return config.Seed
+	}
+	return time.Now().UnixNano()
+}
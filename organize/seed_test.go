@@ -0,0 +1,28 @@
+package organize
+
+import (
+	"testing"
+	"time"
+)
+
+func TestWebhookRetryDelaySeeded(t *testing.T) {
+	defer rng.seed(time.Now().UnixNano())
+
+	delays := func() []time.Duration {
+		rng.seed(42)
+		var d []time.Duration
+		for i := 0; i < 5; i++ {
+			d = append(d, webhookRetryDelay())
+		}
+		return d
+	}
+	first, second := delays(), delays()
+	for i := range first {
+		if first[i] != second[i] {
+			t.Fatalf("delays differ for the same seed: %v and %v", first, second)
+		}
+		if first[i] < time.Second || first[i] >= 3*time.Second {
+			t.Errorf("delay %v outside [1s, 3s)", first[i])
+		}
+	}
+}
@@ -0,0 +1,116 @@
+package organize
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+)
+
+// defaultSidecarTimeField is the sidecar field holding the download time.
+const defaultSidecarTimeField = "download_time"
+
+// hasSidecarConditions reports whether dest matches on its file's sidecar.
+func hasSidecarConditions(dest Destination) bool {
+	return len(dest.Sidecar) > 0 || dest.SidecarMinDays > 0
+}
+
+// isSidecar reports whether the file at path is the sidecar of another
+// file. Sidecars travel with their file instead of being organized on their
+// own.
+func (c *Config) isSidecar(file candidate) bool {
+	if c.SidecarSuffix == "" || file.name == c.SidecarSuffix || !strings.HasSuffix(file.name, c.SidecarSuffix) {
+		return false
+	}
+	_, err := os.Lstat(strings.TrimSuffix(file.path, c.SidecarSuffix))
+	return err == nil
+}
+
+// readSidecar parses the JSON sidecar at path into its top-level fields as
+// strings. String fields holding a URL also yield "<field>.host".
+func readSidecar(path string) (map[string]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var raw map[string]any
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, fmt.Errorf("invalid sidecar %s: %w", path, err)
+	}
+
+	fields := make(map[string]string, len(raw))
+	for key, value := range raw {
+		switch v := value.(type) {
+		case string:
+			fields[key] = v
+			if u, err := url.Parse(v); err == nil && u.Host != "" {
+				fields[key+".host"] = u.Hostname()
+			}
+		case nil, map[string]any, []any:
+		default:
+			fields[key] = fmt.Sprint(v)
+		}
+	}
+	return fields, nil
+}
+
+// matchesSidecar checks dest's sidecar conditions against the sidecar of
+// the file at path. A file without a readable sidecar never matches.
+func matchesSidecar(path string, dest Destination) bool {
+	fields, err := readSidecar(path + dest.sidecarSuffix)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			log.Printf("failed to read sidecar: %v", err)
+		}
+		return false
+	}
+
+	for key, want := range dest.Sidecar {
+		if !strings.EqualFold(fields[key], want) {
+			return false
+		}
+	}
+	if dest.SidecarMinDays > 0 {
+		downloaded, err := time.Parse(time.RFC3339, fields[dest.sidecarTimeField])
+		if err != nil {
+			return false
+		}
+		if clock.Now().Sub(downloaded) < time.Duration(dest.SidecarMinDays*24*float64(time.Hour)) {
+			return false
+		}
+	}
+	return true
+}
+
+// fileSidecar brings the sidecar of sourcePath along after sourcePath was
+// filed to destPath with action: moved (or deleted, with sidecar_delete)
+// for moves and copied for copies. Link actions leave the sidecar alone.
+func fileSidecar(config *Config, action, sourcePath, destPath string) {
+	if config.SidecarSuffix == "" {
+		return
+	}
+	sidecar := sourcePath + config.SidecarSuffix
+	if _, err := os.Lstat(sidecar); err != nil {
+		return
+	}
+
+	var err error
+	switch actionName(action) {
+	case actionMove:
+		if config.SidecarDelete {
+			err = os.Remove(sidecar)
+		} else {
+			err = moveFile(sidecar, destPath+config.SidecarSuffix)
+		}
+	case actionCopy:
+		if !config.SidecarDelete {
+			err = copyTo(sidecar, destPath+config.SidecarSuffix)
+		}
+	}
+	if err != nil && err != errAlreadyFiled {
+		log.Printf("failed to file sidecar %s: %v", sidecar, err)
+	}
+}
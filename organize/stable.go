@@ -0,0 +1,71 @@
+package organize
+
+import (
+	"os"
+	"sync"
+	"time"
+)
+
+// sizeTracker remembers the size each file had in earlier passes, and when
+// that size last changed, to tell files still being written from finished
+// ones for stable_for_seconds.
+type sizeTracker struct {
+	mu    sync.Mutex
+	files map[string]sizeSeen
+}
+
+type sizeSeen struct {
+	size    int64
+	changed time.Time
+}
+
+var sizes = &sizeTracker{files: make(map[string]sizeSeen)}
+
+// stable reports whether the file at path has been left alone for at least
+// window: it was last modified, and its size last seen to change, longer ago
+// than that. A directory's size and modification time are those of the
+// whole tree.
+func (t *sizeTracker) stable(path string, window time.Duration) bool {
+	info, err := os.Lstat(path)
+	if err != nil {
+		// Let the pass see and report the missing file.
+		return true
+	}
+	size, last := info.Size(), info.ModTime()
+	if info.IsDir() {
+		size, last = treeState(path)
+	}
+	now := clock.Now()
+
+	t.mu.Lock()
+	seen, ok := t.files[path]
+	if !ok || seen.size != size {
+		seen.size = size
+		if ok {
+			seen.changed = now
+		}
+		t.files[path] = seen
+	}
+	t.mu.Unlock()
+
+	if seen.changed.After(last) {
+		last = seen.changed
+	}
+	return now.Sub(last) >= window
+}
+
+// prune forgets files that are no longer in the dump directory.
+func (t *sizeTracker) prune(candidates []candidate) {
+	present := make(map[string]bool, len(candidates))
+	for _, file := range candidates {
+		present[file.path] = true
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for path := range t.files {
+		if !present[path] {
+			delete(t.files, path)
+		}
+	}
+}
@@ -0,0 +1,130 @@
+package organize
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// staleTracker remembers when each unmatched file was first seen, across
+// passes and restarts, so files no rule picks up can be moved to stale_dir
+// once they have sat in the dump directory for stale_after_days.
+type staleTracker struct {
+	path string
+	mu   sync.Mutex
+
+	FirstSeen map[string]time.Time `json:"first_seen"`
+}
+
+// loadStaleTracker reads ~/.config/prefix/unmatched.json, starting fresh if
+// it is missing or unreadable.
+func loadStaleTracker() *staleTracker {
+	tracker := &staleTracker{FirstSeen: make(map[string]time.Time)}
+
+	dir, err := configDir()
+	if err != nil {
+		log.Printf("failed to locate unmatched file list: %v", err)
+		return tracker
+	}
+	tracker.path = filepath.Join(dir, "unmatched.json")
+
+	data, err := os.ReadFile(tracker.path)
+	if err != nil {
+		if !errors.Is(err, os.ErrNotExist) {
+			log.Printf("failed to read unmatched file list: %v", err)
+		}
+		return tracker
+	}
+	if err := json.Unmarshal(data, tracker); err != nil {
+		log.Printf("failed to parse unmatched file list, starting fresh: %v", err)
+	}
+	if tracker.FirstSeen == nil {
+		tracker.FirstSeen = make(map[string]time.Time)
+	}
+	return tracker
+}
+
+// age records path as unmatched and returns how long ago it was first seen.
+func (t *staleTracker) age(path string, now time.Time) time.Duration {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	first, ok := t.FirstSeen[path]
+	if !ok {
+		t.FirstSeen[path] = now
+		return 0
+	}
+	return now.Sub(first)
+}
+
+func (t *staleTracker) forget(path string) {
+	t.mu.Lock()
+	delete(t.FirstSeen, path)
+	t.mu.Unlock()
+}
+
+// prune drops entries for files that are no longer candidates, including
+// ones that have since been matched and filed.
+func (t *staleTracker) prune(candidates []candidate) {
+	present := make(map[string]bool, len(candidates))
+	for _, c := range candidates {
+		present[c.path] = true
+	}
+	for path := range t.FirstSeen {
+		if !present[path] {
+			delete(t.FirstSeen, path)
+		}
+	}
+}
+
+func (t *staleTracker) save() {
+	if t.path == "" {
+		return
+	}
+	data, err := json.Marshal(t)
+	if err != nil {
+		log.Printf("failed to encode unmatched file list: %v", err)
+		return
+	}
+
+	tmp := t.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		log.Printf("failed to write unmatched file list: %v", err)
+		return
+	}
+	if err := os.Rename(tmp, t.path); err != nil {
+		log.Printf("failed to replace unmatched file list: %v", err)
+	}
+}
+
+// unmatched handles a file no destination matched: once it has been
+// unmatched for stale_after_days it is moved to stale_dir.
+func (p *passState) unmatched(sourcePath string) {
+	if p.stale == nil {
+		return
+	}
+	threshold := time.Duration(p.config.StaleAfterDays * 24 * float64(time.Hour))
+	if p.stale.age(sourcePath, clock.Now()) < threshold {
+		return
+	}
+
+	destPath, err := safeJoin(p.config.StaleDir, filepath.Base(sourcePath))
+	if err == nil {
+		log.Printf("Moving stale unmatched file: %s -> %s", sourcePath, destPath)
+		err = moveFile(sourcePath, destPath)
+	}
+	if err != nil {
+		log.Printf("failed to move stale file %s: %v", sourcePath, err)
+		events.publish(Event{Type: eventError, Source: sourcePath, Destination: destPath, Error: err.Error()})
+		p.errored()
+		return
+	}
+	p.stale.forget(sourcePath)
+	if p.state != nil {
+		p.state.forget(sourcePath)
+	}
+	events.publish(Event{Type: eventStale, Source: sourcePath, Destination: destPath})
+}
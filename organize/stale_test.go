@@ -0,0 +1,66 @@
+package organize
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestStaleDir(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	if err := os.MkdirAll(filepath.Join(home, ".config", "prefix"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	fake := useFakeClock(t, time.Date(2024, 6, 1, 9, 0, 0, 0, time.UTC))
+	dump := filepath.Join(home, "dump")
+	stale := filepath.Join(home, "stale")
+	writeFile(t, filepath.Join(dump, "unknown.dat"), "data", time.Time{})
+	writeFile(t, filepath.Join(dump, "later.dat"), "data", time.Time{})
+	config := &Config{DumpDirectory: dump, StaleDir: stale, StaleAfterDays: 1, Destinations: []Destination{
+		{Prefix: "a_", Path: filepath.Join(home, "out")},
+	}}
+	if err := config.compile(); err != nil {
+		t.Fatal(err)
+	}
+	pass := func() {
+		t.Helper()
+		if _, err := organizeWith(config, 1); err != nil {
+			t.Fatal(err)
+		}
+	}
+	exists := func(path string) bool {
+		_, err := os.Lstat(path)
+		return err == nil
+	}
+
+	pass()
+	fake.Advance(23 * time.Hour)
+	pass()
+	if exists(filepath.Join(stale, "unknown.dat")) {
+		t.Fatal("file moved to stale_dir before stale_after_days")
+	}
+
+	// A file that goes away and comes back starts over.
+	if err := os.Rename(filepath.Join(dump, "later.dat"), filepath.Join(home, "later.dat")); err != nil {
+		t.Fatal(err)
+	}
+	pass()
+	if err := os.Rename(filepath.Join(home, "later.dat"), filepath.Join(dump, "later.dat")); err != nil {
+		t.Fatal(err)
+	}
+	pass()
+
+	fake.Advance(2 * time.Hour)
+	pass()
+	if got := readFile(t, filepath.Join(stale, "unknown.dat")); got != "data" {
+		t.Errorf("stale_dir/unknown.dat = %q, want %q", got, "data")
+	}
+	if exists(filepath.Join(dump, "unknown.dat")) {
+		t.Error("stale file left in the dump directory")
+	}
+	if !exists(filepath.Join(dump, "later.dat")) || exists(filepath.Join(stale, "later.dat")) {
+		t.Error("file seen again 2h ago moved to stale_dir")
+	}
+}
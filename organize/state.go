@@ -0,0 +1,184 @@
+package organize
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// scanState remembers files that matched no rule, so later passes (and
+// restarts) can skip them until they change or the rules do.
+type scanState struct {
+	path string
+	mu   sync.Mutex
+
+	ConfigHash string                    `json:"config_hash"`
+	Unmatched  map[string]unmatchedEntry `json:"unmatched"`
+}
+
+type unmatchedEntry struct {
+	ModTime time.Time `json:"mod_time"`
+	Size    int64     `json:"size"`
+}
+
+// configDir returns ~/.config/prefix.
+func configDir() (string, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("could not get home directory: %w", err)
+	}
+	return filepath.Join(home, ".config", "prefix"), nil
+}
+
+// matchingHash fingerprints everything deciding whether a file matches, so
+// remembered results are dropped whenever it changes. patterns_file
+// contents are covered by the rules they were expanded into.
+func matchingHash(config *Config) string {
+	data, _ := json.Marshal(struct {
+		Destinations     []Destination
+		DumpDirectories  []string
+		IgnoreExtensions []string
+		SidecarSuffix    string
+		TimeSource       string
+		MatchAll         bool
+	}{
+		Destinations:     config.Destinations,
+		DumpDirectories:  config.dumpDirs(),
+		IgnoreExtensions: config.IgnoreExtensions,
+		SidecarSuffix:    config.SidecarSuffix,
+		TimeSource:       config.TimeSource,
+		MatchAll:         config.MatchAll,
+	})
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// cacheable reports whether a file that matches no rule can be remembered
+// as unmatched. It can't when a rule depends on more than the file's
+// content and name: the current time (filename_before/after in days), its
+// sidecar, or its owner, which can all change without the file changing.
+func (c *Config) cacheable() bool {
+	for _, dest := range c.Destinations {
+		if dest.filenameBefore.relative() || dest.filenameAfter.relative() {
+			return false
+		}
+		if hasSidecarConditions(dest) || dest.MatchOwner != "" {
+			return false
+		}
+	}
+	return true
+}
+
+// loadScanState reads the state file, starting fresh if it is missing,
+// unreadable or was written for different rules.
+func loadScanState(config *Config) *scanState {
+	state := &scanState{
+		ConfigHash: matchingHash(config),
+		Unmatched:  make(map[string]unmatchedEntry),
+	}
+
+	dir, err := configDir()
+	if err != nil {
+		log.Printf("failed to locate state file: %v", err)
+		return state
+	}
+	state.path = filepath.Join(dir, "state.json")
+
+	data, err := os.ReadFile(state.path)
+	if err != nil {
+		if !errors.Is(err, os.ErrNotExist) {
+			log.Printf("failed to read state file: %v", err)
+		}
+		return state
+	}
+
+	var saved scanState
+	if err := json.Unmarshal(data, &saved); err != nil {
+		log.Printf("failed to parse state file, starting fresh: %v", err)
+		return state
+	}
+	if saved.ConfigHash != state.ConfigHash {
+		log.Println("Rules changed since last run, re-evaluating all files")
+		return state
+	}
+	if saved.Unmatched != nil {
+		state.Unmatched = saved.Unmatched
+	}
+	return state
+}
+
+// unchanged reports whether path was already found unmatched and hasn't been
+// modified since.
+func (s *scanState) unchanged(path string) bool {
+	s.mu.Lock()
+	entry, ok := s.Unmatched[path]
+	s.mu.Unlock()
+	if !ok {
+		return false
+	}
+	info, err := os.Lstat(path)
+	if err != nil {
+		return false
+	}
+	return info.ModTime().Equal(entry.ModTime) && info.Size() == entry.Size
+}
+
+func (s *scanState) rememberUnmatched(path string) {
+	info, err := os.Lstat(path)
+	if err != nil {
+		return
+	}
+	s.mu.Lock()
+	s.Unmatched[path] = unmatchedEntry{ModTime: info.ModTime(), Size: info.Size()}
+	s.mu.Unlock()
+}
+
+func (s *scanState) forget(path string) {
+	s.mu.Lock()
+	delete(s.Unmatched, path)
+	s.mu.Unlock()
+}
+
+// prune drops entries for files that are no longer candidates.
+func (s *scanState) prune(candidates []candidate) {
+	present := make(map[string]bool, len(candidates))
+	for _, c := range candidates {
+		present[c.path] = true
+	}
+	for path := range s.Unmatched {
+		if !present[path] {
+			delete(s.Unmatched, path)
+		}
+	}
+}
+
+func (s *scanState) save() {
+	if s.path == "" {
+		return
+	}
+	data, err := json.Marshal(s)
+	if err != nil {
+		log.Printf("failed to encode state: %v", err)
+		return
+	}
+
+	if err := os.MkdirAll(filepath.Dir(s.path), 0o755); err != nil {
+		log.Printf("failed to create state directory: %v", err)
+		return
+	}
+	tmp := s.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		log.Printf("failed to write state file: %v", err)
+		return
+	}
+	if err := os.Rename(tmp, s.path); err != nil {
+		log.Printf("failed to replace state file: %v", err)
+	}
+}
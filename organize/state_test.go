@@ -0,0 +1,90 @@
+package organize
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestCacheable(t *testing.T) {
+	tests := []struct {
+		name string
+		dest Destination
+		want bool
+	}{
+		{"name only", Destination{Prefix: "a_"}, true},
+		{"absolute date", Destination{FilenameDateFormat: "%Y-%m-%d", FilenameBefore: "2024-01-01"}, true},
+		{"days ago", Destination{FilenameDateFormat: "%Y-%m-%d", FilenameBefore: "30d"}, false},
+		{"zero days ago", Destination{FilenameDateFormat: "%Y-%m-%d", FilenameAfter: "0d"}, false},
+		{"sidecar", Destination{Sidecar: map[string]string{"url.host": "github.com"}}, false},
+		{"sidecar age", Destination{SidecarMinDays: 1}, false},
+		{"owner", Destination{MatchOwner: "root"}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.dest.Path = t.TempDir()
+			config := &Config{DumpDirectory: t.TempDir(), SidecarSuffix: ".meta.json", Destinations: []Destination{tt.dest}}
+			if err := config.compile(); err != nil {
+				t.Fatal(err)
+			}
+			if got := config.cacheable(); got != tt.want {
+				t.Errorf("cacheable = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMatchingHash(t *testing.T) {
+	dir := t.TempDir()
+	load := func(config, patterns string) string {
+		t.Helper()
+		writeFile(t, filepath.Join(dir, "prefix.yaml"), config, time.Time{})
+		writeFile(t, filepath.Join(dir, "patterns.txt"), patterns, time.Time{})
+		loaded, err := loadConfigFile(filepath.Join(dir, "prefix.yaml"), "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		return matchingHash(loaded)
+	}
+	base := "dump_directory: " + dir + "\ndestinations:\n  - path: " + dir + "/out\n    patterns_file: patterns.txt\n"
+
+	hash := load(base, "a_\n")
+	if load(base, "a_\n") != hash {
+		t.Fatal("hash differs for the same config")
+	}
+	for name, changed := range map[string]string{
+		"patterns_file contents": load(base, "b_\n"),
+		"ignore_extensions":      load("ignore_extensions: [part]\n"+base, "a_\n"),
+		"sidecar_suffix":         load("sidecar_suffix: .meta.json\n"+base, "a_\n"),
+	} {
+		if changed == hash {
+			t.Errorf("hash unchanged when %s changes", name)
+		}
+	}
+}
+
+func TestScanStateUnchanged(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	dir := t.TempDir()
+	path := filepath.Join(dir, "unmatched.txt")
+	writeFile(t, path, "v1", time.Now().Add(-time.Hour))
+	config := &Config{DumpDirectory: dir}
+
+	state := loadScanState(config)
+	state.rememberUnmatched(path)
+	state.save()
+
+	state = loadScanState(config)
+	if !state.unchanged(path) {
+		t.Error("remembered file not unchanged after reload")
+	}
+	ignoring := *config
+	ignoring.IgnoreExtensions = []string{"part"}
+	if loadScanState(&ignoring).unchanged(path) {
+		t.Error("state kept after the matching config changed")
+	}
+	writeFile(t, path, "v2", time.Now())
+	if state.unchanged(path) {
+		t.Error("modified file still unchanged")
+	}
+}
@@ -0,0 +1,65 @@
+package organize
+
+import (
+	"log"
+	"sync"
+	"time"
+)
+
+// Stats accumulates the results of every organize pass since startup.
+type Stats struct {
+	mu       sync.Mutex
+	started  time.Time
+	passes   int
+	moved    int
+	skipped  int
+	errors   int
+	bytes    int64
+	lastPass time.Time
+	lastMove time.Time
+}
+
+var stats = &Stats{started: clock.Now()}
+
+// record adds the result of one pass.
+func (s *Stats) record(result *OrganizeResult) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := clock.Now()
+	s.passes++
+	s.moved += result.Moved
+	s.skipped += result.Skipped
+	s.errors += result.Errors
+	s.bytes += result.BytesMoved
+	s.lastPass = now
+	if result.Moved > 0 {
+		s.lastMove = now
+	}
+}
+
+// logSummary logs the cumulative stats as a heartbeat.
+func (s *Stats) logSummary() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	last := "never"
+	if !s.lastMove.IsZero() {
+		last = clock.Now().Sub(s.lastMove).Round(time.Second).String() + " ago"
+	}
+	log.Printf("Stats: up %v, %d passes, %d files moved (%s), %d skipped, %d errors, last move %s",
+		clock.Now().Sub(s.started).Round(time.Second), s.passes, s.moved, formatBytes(s.bytes), s.skipped, s.errors, last)
+}
+
+// logSummaries logs the stats every interval until stopped is closed.
+func logSummaries(interval time.Duration, stopped <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			stats.logSummary()
+		case <-stopped:
+			return
+		}
+	}
+}
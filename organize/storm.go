@@ -0,0 +1,55 @@
+package organize
+
+import (
+	"log"
+	"time"
+)
+
+// defaultStormQuiet is how long organizing waits during an event storm
+// when storm_quiet_seconds isn't set.
+const defaultStormQuiet = 30 * time.Second
+
+// stormDetector spots event storms, such as Spotlight or a backup tool
+// touching thousands of files, with a token bucket like rateLimiter's: it
+// refills at the storm rate and holds up to a second's worth of events, so
+// it takes the same memory whatever the rate. It is not safe for
+// concurrent use; fileOrganizer guards it with timerMu.
+type stormDetector struct {
+	tokens   float64
+	last     time.Time
+	storming bool
+}
+
+// observe records an event at now and reports whether events are arriving
+// faster than perSecond, logging when a storm starts and ends.
+func (d *stormDetector) observe(now time.Time, perSecond float64) bool {
+	burst := max(perSecond, 1)
+	if d.last.IsZero() {
+		d.tokens = burst
+	} else {
+		d.tokens = min(burst, d.tokens+now.Sub(d.last).Seconds()*perSecond)
+	}
+	d.last = now
+
+	// An empty bucket means the rate has reached perSecond. Events during
+	// a storm still drain it, so it has to refill before the storm ends.
+	storming := d.tokens < 1
+	d.tokens = max(d.tokens-1, 0)
+
+	if storming && !d.storming {
+		log.Printf("Event storm: over %g events per second, holding off organizing until it subsides", perSecond)
+	} else if !storming && d.storming {
+		log.Println("Event storm subsided")
+	}
+	d.storming = storming
+	return storming
+}
+
+// quiet ends a storm once its events have stopped altogether.
+func (d *stormDetector) quiet() {
+	if d.storming {
+		log.Println("Event storm subsided")
+	}
+	d.storming = false
+	d.last = time.Time{}
+}
@@ -0,0 +1,48 @@
+package organize
+
+import (
+	"testing"
+	"time"
+)
+
+func TestStormDetector(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name      string
+		perSecond float64
+		interval  time.Duration // between events
+		events    int
+		want      bool // storming after the last event
+	}{
+		{"below the rate", 10, 150 * time.Millisecond, 100, false},
+		{"above the rate", 10, 50 * time.Millisecond, 100, true},
+		{"short burst", 10, time.Millisecond, 10, false},
+		{"burst over a second's worth", 10, time.Millisecond, 11, true},
+		{"fractional rate", 0.5, time.Second, 5, true},
+		{"huge rate", 1e12, time.Microsecond, 1000, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var d stormDetector
+			var got bool
+			for i := 0; i < tt.events; i++ {
+				got = d.observe(start.Add(time.Duration(i)*tt.interval), tt.perSecond)
+			}
+			if got != tt.want {
+				t.Errorf("storming = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestStormDetectorQuiet(t *testing.T) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	var d stormDetector
+	for i := 0; i < 20; i++ {
+		d.observe(now, 5)
+	}
+	d.quiet()
+	if d.observe(now, 5) {
+		t.Error("still storming after quiet")
+	}
+}
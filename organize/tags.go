@@ -0,0 +1,219 @@
+package organize
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"unicode/utf16"
+)
+
+// tagFiledFile applies dest.Tags to the file filed at path. Failures are
+// logged but don't fail the filing.
+func tagFiledFile(path string, dest Destination) {
+	if len(dest.Tags) == 0 {
+		return
+	}
+	if err := setTags(path, dest.Tags); err != nil {
+		log.Printf("Warning: could not tag %s: %v", path, err)
+	}
+}
+
+// mergeTags adds the tags not already in existing to it. Finder stores a
+// tag's color after a newline in its name, so only the part before one is
+// compared.
+func mergeTags(existing, tags []string) []string {
+	names := make(map[string]bool, len(existing))
+	for _, tag := range existing {
+		name, _, _ := strings.Cut(tag, "\n")
+		names[name] = true
+	}
+	merged := existing
+	for _, tag := range tags {
+		if !names[tag] {
+			names[tag] = true
+			merged = append(merged, tag)
+		}
+	}
+	return merged
+}
+
+// binaryPlistStrings encodes strs as a binary property list holding one
+// array of strings, the format of macOS's _kMDItemUserTags attribute.
+func binaryPlistStrings(strs []string) []byte {
+	var buf bytes.Buffer
+	buf.WriteString("bplist00")
+
+	numObjects := len(strs) + 1
+	refSize := 1
+	if numObjects > 0xff {
+		refSize = 2
+	}
+	writeRef := func(ref int) {
+		if refSize == 1 {
+			buf.WriteByte(byte(ref))
+		} else {
+			binary.Write(&buf, binary.BigEndian, uint16(ref))
+		}
+	}
+	// writeMarker writes an object marker with its length, spilling lengths
+	// of 15 and over into a following integer object.
+	writeMarker := func(kind byte, n int) {
+		if n < 15 {
+			buf.WriteByte(kind<<4 | byte(n))
+			return
+		}
+		buf.WriteByte(kind<<4 | 0xf)
+		if n <= 0xff {
+			buf.WriteByte(0x10)
+			buf.WriteByte(byte(n))
+		} else {
+			buf.WriteByte(0x11)
+			binary.Write(&buf, binary.BigEndian, uint16(n))
+		}
+	}
+
+	offsets := make([]int, 0, numObjects)
+	offsets = append(offsets, buf.Len())
+	writeMarker(0xa, len(strs))
+	for i := range strs {
+		writeRef(i + 1)
+	}
+
+	for _, s := range strs {
+		offsets = append(offsets, buf.Len())
+		if isASCII(s) {
+			writeMarker(0x5, len(s))
+			buf.WriteString(s)
+			continue
+		}
+		units := utf16.Encode([]rune(s))
+		writeMarker(0x6, len(units))
+		binary.Write(&buf, binary.BigEndian, units)
+	}
+
+	offsetTable := buf.Len()
+	for _, off := range offsets {
+		binary.Write(&buf, binary.BigEndian, uint32(off))
+	}
+
+	buf.Write(make([]byte, 6))
+	buf.WriteByte(4) // offset int size
+	buf.WriteByte(byte(refSize))
+	binary.Write(&buf, binary.BigEndian, uint64(numObjects))
+	binary.Write(&buf, binary.BigEndian, uint64(0)) // top object
+	binary.Write(&buf, binary.BigEndian, uint64(offsetTable))
+	return buf.Bytes()
+}
+
+// parseBinaryPlistStrings decodes a binary property list holding one array
+// of strings, as written by binaryPlistStrings and by Finder.
+func parseBinaryPlistStrings(data []byte) ([]string, error) {
+	const trailerSize = 32
+	if len(data) < len("bplist00")+trailerSize || string(data[:8]) != "bplist00" {
+		return nil, errors.New("not a binary property list")
+	}
+	trailer := data[len(data)-trailerSize:]
+	offsetSize, refSize := int(trailer[6]), int(trailer[7])
+	numObjects := binary.BigEndian.Uint64(trailer[8:])
+	top := binary.BigEndian.Uint64(trailer[16:])
+	offsetTable := binary.BigEndian.Uint64(trailer[24:])
+	if offsetSize < 1 || offsetSize > 8 || refSize < 1 || refSize > 8 || top >= numObjects ||
+		offsetTable > uint64(len(data)) || numObjects > (uint64(len(data))-offsetTable)/uint64(offsetSize) {
+		return nil, errors.New("invalid property list trailer")
+	}
+
+	readInt := func(at, size int) (int, error) {
+		if at < 0 || at+size > len(data) {
+			return 0, errors.New("property list is truncated")
+		}
+		n := 0
+		for _, b := range data[at : at+size] {
+			n = n<<8 | int(b)
+		}
+		return n, nil
+	}
+	// object returns the position of object ref's marker.
+	object := func(ref int) (int, error) {
+		if ref < 0 || uint64(ref) >= numObjects {
+			return 0, fmt.Errorf("object reference %d out of range", ref)
+		}
+		return readInt(int(offsetTable)+ref*offsetSize, offsetSize)
+	}
+	// marker returns an object's kind and length, and where its content
+	// starts, following a length spilled into an integer object.
+	marker := func(at int) (kind byte, n, start int, err error) {
+		if at >= len(data) {
+			return 0, 0, 0, errors.New("property list is truncated")
+		}
+		kind, n = data[at]>>4, int(data[at]&0xf)
+		if n != 0xf {
+			return kind, n, at + 1, nil
+		}
+		if at+1 >= len(data) || data[at+1]>>4 != 0x1 {
+			return 0, 0, 0, errors.New("invalid object length")
+		}
+		size := 1 << (data[at+1] & 0xf)
+		if size > 4 {
+			return 0, 0, 0, errors.New("object length too large")
+		}
+		n, err = readInt(at+2, size)
+		return kind, n, at + 2 + size, err
+	}
+
+	at, err := object(int(top))
+	if err != nil {
+		return nil, err
+	}
+	kind, count, start, err := marker(at)
+	if err != nil {
+		return nil, err
+	}
+	if kind != 0xa {
+		return nil, errors.New("top object is not an array")
+	}
+	strs := make([]string, 0, min(count, len(data)))
+	for i := 0; i < count; i++ {
+		ref, err := readInt(start+i*refSize, refSize)
+		if err != nil {
+			return nil, err
+		}
+		if at, err = object(ref); err != nil {
+			return nil, err
+		}
+		kind, n, content, err := marker(at)
+		if err != nil {
+			return nil, err
+		}
+		switch kind {
+		case 0x5:
+			if content+n > len(data) {
+				return nil, errors.New("property list is truncated")
+			}
+			strs = append(strs, string(data[content:content+n]))
+		case 0x6:
+			if content+2*n > len(data) {
+				return nil, errors.New("property list is truncated")
+			}
+			units := make([]uint16, n)
+			for j := range units {
+				units[j] = binary.BigEndian.Uint16(data[content+2*j:])
+			}
+			strs = append(strs, string(utf16.Decode(units)))
+		default:
+			return nil, fmt.Errorf("array holds an object of kind %#x, not a string", kind)
+		}
+	}
+	return strs, nil
+}
+
+func isASCII(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] >= 0x80 {
+			return false
+		}
+	}
+	return true
+}
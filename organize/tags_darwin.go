@@ -0,0 +1,28 @@
+package organize
+
+import (
+	"fmt"
+
+	"golang.org/x/sys/unix"
+)
+
+// finderTagsAttr holds a file's Finder tags as a binary property list.
+const finderTagsAttr = "com.apple.metadata:_kMDItemUserTags"
+
+// errNoAttr is what Getxattr fails with for an attribute that isn't set.
+const errNoAttr = unix.ENOATTR
+
+// setTags adds tags to the file's Finder tags, keeping those it had.
+func setTags(path string, tags []string) error {
+	value, err := readXattr(path, finderTagsAttr)
+	if err != nil {
+		return err
+	}
+	var existing []string
+	if len(value) > 0 {
+		if existing, err = parseBinaryPlistStrings(value); err != nil {
+			return fmt.Errorf("can't read existing Finder tags: %w", err)
+		}
+	}
+	return unix.Setxattr(path, finderTagsAttr, binaryPlistStrings(mergeTags(existing, tags)), 0)
+}
@@ -0,0 +1,27 @@
+package organize
+
+import (
+	"strings"
+
+	"golang.org/x/sys/unix"
+)
+
+// xdgTagsAttr is the freedesktop.org extended attribute for tags, a
+// comma-separated list read by file managers such as Dolphin.
+const xdgTagsAttr = "user.xdg.tags"
+
+// errNoAttr is what Getxattr fails with for an attribute that isn't set.
+const errNoAttr = unix.ENODATA
+
+// setTags adds tags to the file's user.xdg.tags, keeping those it had.
+func setTags(path string, tags []string) error {
+	value, err := readXattr(path, xdgTagsAttr)
+	if err != nil {
+		return err
+	}
+	var existing []string
+	if len(value) > 0 {
+		existing = strings.Split(string(value), ",")
+	}
+	return unix.Setxattr(path, xdgTagsAttr, []byte(strings.Join(mergeTags(existing, tags), ",")), 0)
+}
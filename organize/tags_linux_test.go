@@ -0,0 +1,53 @@
+package organize
+
+import (
+	"errors"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+func TestSetTagsKeepsExisting(t *testing.T) {
+	// The long list is over the 4096 bytes a fixed read buffer would hold;
+	// ext4 can't store it, so it only runs on filesystems such as XFS.
+	for _, n := range []int{3, 800} {
+		path := filepath.Join(t.TempDir(), "report.pdf")
+		writeFile(t, path, "pdf", time.Time{})
+		existing := make([]string, 0, n)
+		for i := 0; i < n; i++ {
+			existing = append(existing, "tag"+strings.Repeat("x", i%10))
+		}
+		if err := unix.Setxattr(path, xdgTagsAttr, []byte(strings.Join(existing, ",")), 0); err != nil {
+			t.Logf("can't store %d tags on this filesystem: %v", n, err)
+			continue
+		}
+
+		if err := setTags(path, []string{"Receipts", "tag"}); err != nil {
+			t.Fatalf("setTags: %v", err)
+		}
+		value, err := readXattr(path, xdgTagsAttr)
+		if err != nil {
+			t.Fatalf("readXattr: %v", err)
+		}
+		want := append(slices.Clone(existing), "Receipts")
+		if got := strings.Split(string(value), ","); !slices.Equal(got, want) {
+			t.Errorf("with %d tags: got %d, want %d ending in Receipts", n, len(got), len(want))
+		}
+	}
+}
+
+func TestReadXattrMissing(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "report.pdf")
+	writeFile(t, path, "pdf", time.Time{})
+	value, err := readXattr(path, xdgTagsAttr)
+	if err != nil && !errors.Is(err, unix.ENOTSUP) {
+		t.Fatalf("readXattr: %v", err)
+	}
+	if value != nil {
+		t.Errorf("readXattr = %q, want nil", value)
+	}
+}
@@ -0,0 +1,19 @@
+//go:build !darwin && !linux
+
+package organize
+
+import (
+	"errors"
+	"sync"
+)
+
+var tagsWarning sync.Once
+
+// setTags is not supported on this platform, which is only reported once.
+func setTags(path string, tags []string) error {
+	var err error
+	tagsWarning.Do(func() {
+		err = errors.New("tags are only supported on macOS and Linux, ignoring them")
+	})
+	return err
+}
@@ -0,0 +1,49 @@
+package organize
+
+import (
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestBinaryPlistStringsRoundTrip(t *testing.T) {
+	tests := [][]string{
+		{},
+		{"Receipts"},
+		{"Receipts", "Tax\n6"},
+		{"a tag longer than fifteen characters", "Überweisung", "税金"},
+		{strings.Repeat("x", 300)},
+	}
+	for _, strs := range tests {
+		got, err := parseBinaryPlistStrings(binaryPlistStrings(strs))
+		if err != nil {
+			t.Errorf("parseBinaryPlistStrings(%q): %v", strs, err)
+			continue
+		}
+		if !slices.Equal(got, strs) {
+			t.Errorf("round trip of %q = %q", strs, got)
+		}
+	}
+}
+
+func TestParseBinaryPlistStringsInvalid(t *testing.T) {
+	valid := binaryPlistStrings([]string{"Receipts", "Tax"})
+	tests := map[string][]byte{
+		"empty":     nil,
+		"not plist": []byte(strings.Repeat("x", 64)),
+		"truncated": append([]byte("bplist00"), valid[len(valid)-32:]...),
+	}
+	for name, data := range tests {
+		if _, err := parseBinaryPlistStrings(data); err == nil {
+			t.Errorf("%s: parseBinaryPlistStrings succeeded", name)
+		}
+	}
+}
+
+func TestMergeTags(t *testing.T) {
+	got := mergeTags([]string{"Work\n4", "Tax"}, []string{"Work", "Receipts", "Tax", "Receipts"})
+	want := []string{"Work\n4", "Tax", "Receipts"}
+	if !slices.Equal(got, want) {
+		t.Errorf("mergeTags = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,39 @@
+//go:build darwin || linux
+
+package organize
+
+import (
+	"errors"
+
+	"golang.org/x/sys/unix"
+)
+
+// readXattr returns the value of the extended attribute attr of path, or
+// nil if it isn't set. Its size is probed first, retrying if the attribute
+// grows in between, so values of any length are read whole.
+func readXattr(path, attr string) ([]byte, error) {
+	for {
+		size, err := unix.Getxattr(path, attr, nil)
+		if errors.Is(err, errNoAttr) {
+			return nil, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		if size == 0 {
+			return nil, nil
+		}
+		buf := make([]byte, size)
+		n, err := unix.Getxattr(path, attr, buf)
+		if errors.Is(err, unix.ERANGE) {
+			continue
+		}
+		if errors.Is(err, errNoAttr) {
+			return nil, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		return buf[:n], nil
+	}
+}
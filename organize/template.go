@@ -0,0 +1,185 @@
+package organize
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+const (
+	tokenMissingSkip  = "skip"
+	tokenMissingError = "error"
+)
+
+// errSkipRule reports that a matching destination can't be used for a file,
+// so routing should fall through to the next matching destination.
+var errSkipRule = errors.New("destination does not apply to file")
+
+var positionalToken = regexp.MustCompile(`\{(\d+)\}`)
+
+var namedToken = regexp.MustCompile(`\{([A-Za-z_][A-Za-z0-9_]*)\}`)
+
+// hashToken expands to the first two hex characters of the file's hash, to
+// shard a large store into at most 256 buckets like git's objects directory.
+const hashToken = "{{hash2}}"
+
+const (
+	hashSourceContent = "content"
+	hashSourceName    = "name"
+)
+
+// expandPath fills in the placeholders in dest.Path for the file at path
+// named filename. With a delimiter set, {n} is replaced by the nth (1-based)
+// delimiter-separated segment of the name without its extension, and with
+// a regex set, {name} by the named capture group; see expandFileTokens for
+// the rest. path may be empty when only the name is known, in which case
+// the date tokens and a content-based {{hash2}} are left as is.
+func expandPath(dest Destination, timeSource, path, filename string) (string, error) {
+	dir, err := expandSegments(dest, filename)
+	if err == nil {
+		dir, err = expandCaptures(dest, dir, filename)
+	}
+	if err == nil {
+		dir, err = expandFileTokens(dest, dir, timeSource, path, filename)
+	}
+	if err != nil || !strings.Contains(dir, hashToken) {
+		return dir, err
+	}
+
+	var sum string
+	if dest.HashSource == hashSourceName {
+		hash := sha256.Sum256([]byte(filename))
+		sum = hex.EncodeToString(hash[:])
+	} else if path != "" {
+		if sum, err = fileSHA256(path); err != nil {
+			return "", err
+		}
+	} else {
+		return dir, nil
+	}
+	return strings.ReplaceAll(dir, hashToken, sum[:2]), nil
+}
+
+// expandSegments replaces the {n} placeholders in dest.Path.
+func expandSegments(dest Destination, filename string) (string, error) {
+	if dest.Delimiter == "" {
+		return dest.Path, nil
+	}
+
+	stem := strings.TrimSuffix(filename, filepath.Ext(filename))
+	segments := strings.Split(stem, dest.Delimiter)
+
+	var missing error
+	path := positionalToken.ReplaceAllStringFunc(dest.Path, func(token string) string {
+		n, _ := strconv.Atoi(token[1 : len(token)-1])
+		if n < 1 || n > len(segments) || !validSegment(segments[n-1]) {
+			if missing == nil {
+				missing = fmt.Errorf("%s has no segment %s (split on %q)", filename, token, dest.Delimiter)
+			}
+			return token
+		}
+		return segments[n-1]
+	})
+
+	if missing != nil {
+		if dest.TokenMissing == tokenMissingError {
+			return "", missing
+		}
+		return "", fmt.Errorf("%w: %v", errSkipRule, missing)
+	}
+	return path, nil
+}
+
+// expandCaptures replaces the {name} placeholders in path with the named
+// groups of dest.Regex matched against filename. Placeholders that aren't
+// groups of the regex, such as {{hash2}}, are left as is.
+func expandCaptures(dest Destination, path, filename string) (string, error) {
+	if dest.regex == nil {
+		return path, nil
+	}
+	match := dest.regex.FindStringSubmatchIndex(filename)
+	if match == nil {
+		return path, nil
+	}
+
+	var missing error
+	path = namedToken.ReplaceAllStringFunc(path, func(token string) string {
+		group := dest.regex.SubexpIndex(token[1 : len(token)-1])
+		if group < 0 {
+			return token
+		}
+		start, end := match[2*group], match[2*group+1]
+		if start < 0 || !validSegment(filename[start:end]) {
+			if missing == nil {
+				missing = fmt.Errorf("%s has no usable group %s (regex %q)", filename, token, dest.Regex)
+			}
+			return token
+		}
+		return filename[start:end]
+	})
+
+	if missing != nil {
+		if dest.TokenMissing == tokenMissingError {
+			return "", missing
+		}
+		return "", fmt.Errorf("%w: %v", errSkipRule, missing)
+	}
+	return path, nil
+}
+
+// fileTokens are the path placeholders filled in from the file itself.
+var fileTokens = []string{"{year}", "{month}", "{day}", "{name}", "{stem}", "{ext}"}
+
+// expandFileTokens replaces {year}, {month} and {day} in dir with the
+// file's timestamp (see time_source), and {name}, {stem} and {ext} as in
+// rename_pattern, with the whole name, the name without its extension and
+// the extension including its dot. A name without an extension for {ext},
+// or nothing before it for {stem}, is handled as TokenMissing says.
+func expandFileTokens(dest Destination, dir, timeSource, path, filename string) (string, error) {
+	if !slices.ContainsFunc(fileTokens, func(token string) bool { return strings.Contains(dir, token) }) {
+		return dir, nil
+	}
+
+	ext := filepath.Ext(filename)
+	stem := strings.TrimSuffix(filename, ext)
+	var missing error
+	if strings.Contains(dir, "{ext}") && !validSegment(ext) {
+		missing = fmt.Errorf("%s has no extension for {ext}", filename)
+	} else if strings.Contains(dir, "{stem}") && !validSegment(stem) {
+		missing = fmt.Errorf("%s has no name before its extension for {stem}", filename)
+	}
+	if missing != nil {
+		if dest.TokenMissing == tokenMissingError {
+			return "", missing
+		}
+		return "", fmt.Errorf("%w: %v", errSkipRule, missing)
+	}
+	replacements := nameTokens(filename)
+
+	if path != "" {
+		info, err := os.Lstat(path)
+		if err != nil {
+			return "", err
+		}
+		t := fileTime(path, info, timeSource)
+		replacements = append(replacements,
+			"{year}", t.Format("2006"),
+			"{month}", t.Format("01"),
+			"{day}", t.Format("02"),
+		)
+	}
+	return strings.NewReplacer(replacements...).Replace(dir), nil
+}
+
+// validSegment reports whether a filename segment is safe to use as a path
+// component.
+func validSegment(segment string) bool {
+	return segment != "" && segment != "." && segment != ".." && !strings.ContainsRune(segment, filepath.Separator)
+}
@@ -0,0 +1,55 @@
+package organize
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestExpandFileTokens(t *testing.T) {
+	mtime := time.Date(2024, 5, 7, 12, 0, 0, 0, time.Local)
+	tests := []struct {
+		name     string
+		filename string
+		path     string
+		want     string
+		wantSkip bool
+	}{
+		{name: "date", filename: "shot.png", path: "/out/{year}/{month}/{day}", want: "/out/2024/05/07"},
+		{name: "name", filename: "report.pdf", path: "/out/{name}", want: "/out/report.pdf"},
+		{name: "stem", filename: "report.pdf", path: "/out/{stem}", want: "/out/report"},
+		{name: "ext has its dot", filename: "report.pdf", path: "/out/by{ext}", want: "/out/by.pdf"},
+		{name: "last extension only", filename: "backup.tar.gz", path: "/out/{stem}", want: "/out/backup.tar"},
+		{name: "no extension", filename: "README", path: "/out/{ext}", wantSkip: true},
+		{name: "no stem", filename: ".bashrc", path: "/out/{stem}", wantSkip: true},
+		{name: "no tokens", filename: "README", path: "/out", want: "/out"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			file := filepath.Join(t.TempDir(), tt.filename)
+			writeFile(t, file, "", mtime)
+
+			got, err := expandFileTokens(Destination{}, tt.path, timeSourceMtime, file, tt.filename)
+			if tt.wantSkip {
+				if !errors.Is(err, errSkipRule) {
+					t.Fatalf("err = %v, want errSkipRule", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("expandFileTokens(%q, %q) = %q, want %q", tt.path, tt.filename, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestExpandFileTokensMissingError(t *testing.T) {
+	_, err := expandFileTokens(Destination{TokenMissing: tokenMissingError}, "/out/{ext}", "", "", "README")
+	if err == nil || errors.Is(err, errSkipRule) {
+		t.Errorf("err = %v, want an error that doesn't skip the rule", err)
+	}
+}
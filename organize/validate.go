@@ -0,0 +1,200 @@
+package organize
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/robfig/cron/v3"
+)
+
+// Validate checks the loaded config and returns every problem it finds, so
+// they can all be fixed in one go.
+func (c *Config) Validate() []error {
+	var problems []error
+	add := func(format string, args ...any) {
+		problems = append(problems, fmt.Errorf(format, args...))
+	}
+
+	if c.DumpDirectory == "" && len(c.DumpDirectories) == 0 {
+		add("dump_directory is empty in config file and no dump_directories are set")
+	}
+	for _, dir := range c.DumpDirectories {
+		if dir == "" {
+			add("dump_directories must not contain empty paths")
+			break
+		}
+	}
+	if len(c.Destinations) == 0 {
+		add("no destinations configured")
+	}
+	if !validLogTimestampFormat(c.LogTimestampFormat) {
+		add("log_timestamp_format must be one of local, utc, rfc3339")
+	}
+	if c.TimeSource != "" && c.TimeSource != timeSourceMtime && c.TimeSource != timeSourceBtime {
+		add("time_source must be %q or %q, got %q", timeSourceMtime, timeSourceBtime, c.TimeSource)
+	}
+	switch c.OnLongPath {
+	case "", longPathSkip, longPathTruncate, longPathError:
+	default:
+		add("on_long_path must be one of skip, truncate, error")
+	}
+	if c.DebounceSeconds < 0 {
+		add("debounce_seconds must not be negative")
+	}
+	if c.Workers < 0 || c.InitialWorkers < 0 {
+		add("workers and initial_workers must not be negative")
+	}
+	if c.StormEventsPerSecond < 0 {
+		add("storm_events_per_second must not be negative")
+	}
+	if c.StormQuietSeconds < 0 {
+		add("storm_quiet_seconds must not be negative")
+	}
+	if !validProcessOrder(c.ProcessOrder) {
+		add("process_order must be one of %s, %s, %s, %s", orderName, orderSizeDesc, orderMtimeAsc, orderMtimeDesc)
+	}
+	for _, ext := range c.IgnoreExtensions {
+		if strings.Trim(ext, ".") == "" {
+			add("ignore_extensions must not contain empty extensions")
+			break
+		}
+	}
+	if c.StableForSeconds < 0 {
+		add("stable_for_seconds must not be negative")
+	}
+	if c.SummaryIntervalMinutes < 0 {
+		add("summary_interval_minutes must not be negative")
+	}
+	if c.ShutdownTimeoutSeconds < 0 {
+		add("shutdown_timeout_seconds must not be negative")
+	}
+	if c.SettleSeconds < 0 {
+		add("settle_seconds must not be negative")
+	}
+	if c.Schedule != "" {
+		if _, err := cron.ParseStandard(c.Schedule); err != nil {
+			add("invalid schedule %q: %v", c.Schedule, err)
+		}
+	}
+	if len(c.ScanCommand) > 0 && c.QuarantineDirectory == "" {
+		add("scan_command needs quarantine_directory")
+	}
+	if c.ScanTimeoutSeconds < 0 {
+		add("scan_timeout_seconds must not be negative")
+	}
+	if c.StaleDir != "" && c.StaleAfterDays <= 0 {
+		add("stale_dir needs a positive stale_after_days")
+	}
+	for i, dest := range c.Destinations {
+		if dest.Path == "" {
+			add("destination[%d] has empty path", i)
+		}
+		for _, name := range dest.Exact {
+			if name == "" || strings.ContainsRune(name, '/') {
+				add("destination[%d] exact names must be non-empty file names without a /", i)
+				break
+			}
+		}
+		for _, ext := range dest.Extensions {
+			if strings.Trim(ext, ".") == "" || strings.ContainsRune(ext, '/') {
+				add("destination[%d] extensions must be non-empty and without a /, e.g. pdf", i)
+				break
+			}
+		}
+		if dest.MinWidth < 0 || dest.MinHeight < 0 || dest.MaxWidth < 0 || dest.MaxHeight < 0 {
+			add("destination[%d] image width and height bounds must not be negative", i)
+		}
+		if dest.ContainsMaxBytes < 0 {
+			add("destination[%d] contains_max_bytes must not be negative", i)
+		}
+		if dest.DebounceSeconds < 0 {
+			add("destination[%d] debounce_seconds must not be negative", i)
+		}
+		if dest.StemExtension != "" && dest.StemExtension != stemExtensionLast && dest.StemExtension != stemExtensionAll {
+			add("destination[%d] stem_extension must be %q or %q", i, stemExtensionLast, stemExtensionAll)
+		}
+		if dest.HashSource != "" && dest.HashSource != hashSourceContent && dest.HashSource != hashSourceName {
+			add("destination[%d] hash_source must be %q or %q", i, hashSourceContent, hashSourceName)
+		}
+		for _, tag := range dest.Tags {
+			if tag == "" || strings.ContainsAny(tag, ",\n") {
+				add("destination[%d] tags must be non-empty and can't contain commas or newlines", i)
+				break
+			}
+		}
+		for j, step := range dest.Pipeline {
+			if step.Name == "" {
+				add("destination[%d] pipeline step %d has no name", i, j)
+			}
+			if len(step.Command) == 0 {
+				add("destination[%d] pipeline step %d has no command", i, j)
+			}
+			if step.TimeoutSeconds < 0 {
+				add("destination[%d] pipeline step %d timeout_seconds must not be negative", i, j)
+			}
+		}
+		if dest.IncludeDirectories {
+			if c.Recursive {
+				add("destination[%d] include_directories can't be used with recursive", i)
+			}
+			if actionName(dest.Action) != actionMove {
+				add("destination[%d] include_directories only works with the move action", i)
+			}
+			if dest.Encrypt != "" || dest.Magic != "" || hasContainsConditions(dest) || hasDimensionConditions(dest) || hasSidecarConditions(dest) || dest.KeepNewestMatching {
+				add("destination[%d] include_directories can't be combined with encrypt, magic, contains, image size, sidecar or keep_newest_matching", i)
+			}
+		}
+		if dest.Encrypt != "" && actionName(dest.Action) != actionMove && dest.Action != actionCopy {
+			add("destination[%d] encrypt only works with the move and copy actions", i)
+		}
+		for _, dir := range dest.DumpDirectories {
+			if !slices.Contains(c.dumpDirs(), filepath.Clean(dir)) {
+				add("destination[%d] dump_directories entry %q is not a configured dump directory", i, dir)
+			}
+		}
+		if !validOnConflict(dest.OnConflict) {
+			add("destination[%d] on_conflict must be one of skip, overwrite, rename, newer", i)
+		}
+		if !validAction(dest.Action) {
+			add("destination[%d] action must be one of move, copy, symlink, hardlink", i)
+		}
+		if dest.TokenMissing != "" && dest.TokenMissing != tokenMissingSkip && dest.TokenMissing != tokenMissingError {
+			add("destination[%d] token_missing must be %q or %q", i, tokenMissingSkip, tokenMissingError)
+		}
+		if !hasNameConditions(dest) && !hasFileConditions(dest) && dest.Magic == "" && dest.ContainsRegex == "" && len(dest.Routes) == 0 && dest.PatternsFile == "" {
+			add("destination[%d] must have at least prefix, suffix, exact, extensions, pattern, regex, filename_date_format, patterns_file, magic, contains, image size, match_owner, sidecar, source_dir or dump_directories", i)
+		}
+	}
+	return problems
+}
+
+// splitErrors flattens an error built with errors.Join into its parts.
+func splitErrors(err error) []error {
+	if err == nil {
+		return nil
+	}
+	if joined, ok := err.(interface{ Unwrap() []error }); ok {
+		var errs []error
+		for _, e := range joined.Unwrap() {
+			errs = append(errs, splitErrors(e)...)
+		}
+		return errs
+	}
+	return []error{err}
+}
+
+// cmdValidate prints every config problem and exits 1 if there are any.
+func cmdValidate(problems []error) int {
+	if len(problems) == 0 {
+		fmt.Println("config is valid")
+		return 0
+	}
+	for _, problem := range problems {
+		fmt.Fprintln(os.Stderr, problem)
+	}
+	fmt.Fprintf(os.Stderr, "%d problem(s) found\n", len(problems))
+	return 1
+}
@@ -0,0 +1,178 @@
+package organize
+
+import (
+	"errors"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"syscall"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// collectDir appends the files in dir to candidates, descending into
+// subdirectories when recursive is set. Unreadable subdirectories are logged
+// and skipped. Otherwise, with includeDirs the subdirectories themselves are
+// candidates.
+func collectDir(dir string, recursive, includeDirs bool, candidates *[]candidate) error {
+	root, err := filepath.EvalSymlinks(dir)
+	if err != nil {
+		root = dir
+	}
+	return collectTree(root, dir, recursive, includeDirs, candidates, make(map[string]bool))
+}
+
+// collectTree does the work of collectDir. In recursive mode, symlinks to
+// directories inside root (the resolved dump directory) are followed, and
+// visited tracks the directories already walked so a symlink pointing back
+// up the tree can't loop forever. A symlink to a directory elsewhere is a
+// candidate like any other entry, as it is without recursive.
+func collectTree(root, dir string, recursive, includeDirs bool, candidates *[]candidate, visited map[string]bool) error {
+	if recursive {
+		if id, ok := dirID(dir); ok {
+			if visited[id] {
+				log.Printf("Skipping already visited directory (symlink loop?): %s", dir)
+				return nil
+			}
+			visited[id] = true
+		}
+	}
+
+	files, err := os.ReadDir(dir)
+	if err != nil {
+		return err
+	}
+
+	for _, file := range files {
+		path := filepath.Join(dir, file.Name())
+		isDir := file.IsDir()
+		if recursive && file.Type()&os.ModeSymlink != 0 {
+			if info, err := os.Stat(path); err == nil && info.IsDir() {
+				isDir = insideRoot(root, path)
+			}
+		}
+
+		if isDir {
+			if recursive {
+				if err := collectTree(root, path, true, false, candidates, visited); err != nil {
+					log.Printf("failed to read subdirectory %s: %v", path, err)
+				}
+			} else if includeDirs {
+				*candidates = append(*candidates, candidate{path: path, name: file.Name()})
+			}
+			continue
+		}
+		*candidates = append(*candidates, candidate{path: path, name: file.Name()})
+	}
+	return nil
+}
+
+// insideRoot reports whether path resolves to root or a directory below it.
+func insideRoot(root, path string) bool {
+	target, err := filepath.EvalSymlinks(path)
+	if err != nil {
+		return false
+	}
+	rel, err := filepath.Rel(root, target)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+// removeEmptyDirs removes every empty subdirectory of root, deepest first.
+// root itself is never removed.
+func removeEmptyDirs(root string) {
+	entries, err := os.ReadDir(root)
+	if err != nil {
+		log.Printf("failed to read dump directory: %v", err)
+		return
+	}
+	for _, entry := range entries {
+		if entry.IsDir() {
+			removeEmptyTree(filepath.Join(root, entry.Name()))
+		}
+	}
+}
+
+// removeEmptyTree removes dir if it contains nothing but empty directories,
+// and reports whether it did.
+func removeEmptyTree(dir string) bool {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		log.Printf("failed to read directory %s: %v", dir, err)
+		return false
+	}
+
+	empty := true
+	for _, entry := range entries {
+		if entry.IsDir() && removeEmptyTree(filepath.Join(dir, entry.Name())) {
+			continue
+		}
+		empty = false
+	}
+	if !empty {
+		return false
+	}
+
+	if err := os.Remove(dir); err != nil {
+		log.Printf("failed to remove empty directory: %v", err)
+		return false
+	}
+	log.Printf("Removed empty directory: %s", dir)
+	return true
+}
+
+// watchLimitWarning makes sure the watch limit is explained once rather
+// than for every directory that couldn't be watched.
+var watchLimitWarning sync.Once
+
+// addWatch adds path to the watcher. On Linux, running out of inotify
+// watches fails with ENOSPC, which fsnotify reports as "no space left on
+// device"; that is logged with how to raise the limit.
+func addWatch(watcher *fsnotify.Watcher, path string) error {
+	err := watcher.Add(path)
+	if errors.Is(err, syscall.ENOSPC) {
+		watchLimitWarning.Do(func() {
+			log.Printf("Ran out of inotify watches at %s: the limit is set by fs.inotify.max_user_watches. "+
+				"Raise it with e.g. \"sudo sysctl fs.inotify.max_user_watches=524288\" (add it to /etc/sysctl.conf to keep it). "+
+				"Continuing with the directories already watched", path)
+		})
+	}
+	return err
+}
+
+// watchTree adds root to the watcher and, when recursive is set, every
+// directory below it. Subdirectories that can't be watched are logged and
+// skipped; the counts are logged once the tree has been walked.
+func watchTree(watcher *fsnotify.Watcher, root string, recursive bool) error {
+	if err := addWatch(watcher, root); err != nil {
+		return err
+	}
+	if !recursive {
+		return nil
+	}
+
+	watched, skipped := 1, 0
+	err := filepath.WalkDir(root, func(path string, d os.DirEntry, err error) error {
+		if err != nil {
+			log.Printf("failed to walk %s: %v", path, err)
+			return nil
+		}
+		if !d.IsDir() || path == root {
+			return nil
+		}
+		if err := addWatch(watcher, path); err != nil {
+			log.Printf("failed to watch %s: %v", path, err)
+			skipped++
+			return nil
+		}
+		watched++
+		return nil
+	})
+	if skipped > 0 {
+		log.Printf("Watching %d directories under %s, %d could not be watched", watched, root, skipped)
+	} else {
+		log.Printf("Watching %d directories under %s", watched, root)
+	}
+	return err
+}
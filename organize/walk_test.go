@@ -0,0 +1,145 @@
+package organize
+
+import (
+	"maps"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestCollectDirSymlinks(t *testing.T) {
+	dir := t.TempDir()
+	dump := filepath.Join(dir, "dump")
+	writeFile(t, filepath.Join(dump, "sub", "inner.txt"), "", time.Time{})
+	writeFile(t, filepath.Join(dir, "elsewhere", "outer.txt"), "", time.Time{})
+	for link, target := range map[string]string{
+		"linked":  filepath.Join(dump, "sub"),
+		"loop":    dump,
+		"outside": filepath.Join(dir, "elsewhere"),
+	} {
+		if err := os.Symlink(target, filepath.Join(dump, link)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var candidates []candidate
+	if err := collectDir(dump, true, false, &candidates); err != nil {
+		t.Fatal(err)
+	}
+	// sub is walked once, either directly or through linked, and the
+	// symlink out of the dump directory is a candidate itself, not walked.
+	counts := make(map[string]int)
+	for _, c := range candidates {
+		counts[filepath.Base(c.path)]++
+	}
+	want := map[string]int{"inner.txt": 1, "outside": 1}
+	if !maps.Equal(counts, want) {
+		t.Errorf("candidates by name = %v, want %v", counts, want)
+	}
+}
+
+func TestRenameNumberingIsDeterministic(t *testing.T) {
+	run := func() map[string]string {
+		dir := t.TempDir()
+		dump := filepath.Join(dir, "dump")
+		inbox := filepath.Join(dir, "inbox")
+		out := filepath.Join(dir, "out")
+		// Created out of order, across two dump directories and their
+		// subdirectories.
+		for _, path := range []string{
+			filepath.Join(inbox, "a_x.txt"),
+			filepath.Join(dump, "zz", "a_x.txt"),
+			filepath.Join(dump, "a_x.txt"),
+			filepath.Join(dump, "b", "a_x.txt"),
+			filepath.Join(dump, "a", "a_x.txt"),
+		} {
+			rel, _ := filepath.Rel(dir, path)
+			writeFile(t, path, rel, time.Time{})
+		}
+		config := &Config{DumpDirectory: dump, DumpDirectories: []string{inbox}, Recursive: true, Destinations: []Destination{
+			{Prefix: "a_", Path: out, OnConflict: onConflictRename},
+		}}
+		if err := config.compile(); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := organizeWith(config, 1); err != nil {
+			t.Fatal(err)
+		}
+		filed := make(map[string]string)
+		entries, err := os.ReadDir(out)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, entry := range entries {
+			filed[entry.Name()] = readFile(t, filepath.Join(out, entry.Name()))
+		}
+		return filed
+	}
+
+	want := map[string]string{
+		"a_x.txt":     filepath.Join("dump", "a", "a_x.txt"),
+		"a_x (1).txt": filepath.Join("dump", "a_x.txt"),
+		"a_x (2).txt": filepath.Join("dump", "b", "a_x.txt"),
+		"a_x (3).txt": filepath.Join("dump", "zz", "a_x.txt"),
+		"a_x (4).txt": filepath.Join("inbox", "a_x.txt"),
+	}
+	for i := 0; i < 3; i++ {
+		if got := run(); !maps.Equal(got, want) {
+			t.Fatalf("run %d filed %v, want %v", i, got, want)
+		}
+	}
+}
+
+func TestRemoveEmptyDirs(t *testing.T) {
+	dir := t.TempDir()
+	dump := filepath.Join(dir, "dump")
+	out := filepath.Join(dir, "out")
+	writeFile(t, filepath.Join(dump, "photos", "a_1.jpg"), "", time.Time{})
+	writeFile(t, filepath.Join(dump, "photos", "2024", "a_2.jpg"), "", time.Time{})
+	writeFile(t, filepath.Join(dump, "mixed", "a_3.jpg"), "", time.Time{})
+	writeFile(t, filepath.Join(dump, "mixed", "keep.txt"), "", time.Time{})
+	writeFile(t, filepath.Join(dump, "deep", "er", "keep.txt"), "", time.Time{})
+	writeFile(t, filepath.Join(dump, "deep", "a_4.jpg"), "", time.Time{})
+	if err := os.MkdirAll(filepath.Join(dump, "already", "empty"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	config := &Config{DumpDirectory: dump, Recursive: true, RemoveEmptyDirs: true, Destinations: []Destination{
+		{Prefix: "a_", Path: out, OnConflict: onConflictRename},
+	}}
+	if err := config.compile(); err != nil {
+		t.Fatal(err)
+	}
+	result, err := organizeWith(config, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Moved != 4 {
+		t.Fatalf("moved %d files, want 4", result.Moved)
+	}
+
+	for _, path := range []string{"photos", "already"} {
+		if _, err := os.Lstat(filepath.Join(dump, path)); !os.IsNotExist(err) {
+			t.Errorf("empty directory %s not removed: %v", path, err)
+		}
+	}
+	for _, path := range []string{filepath.Join("mixed", "keep.txt"), filepath.Join("deep", "er", "keep.txt")} {
+		if _, err := os.Lstat(filepath.Join(dump, path)); err != nil {
+			t.Errorf("%s not preserved: %v", path, err)
+		}
+	}
+	if _, err := os.Lstat(dump); err != nil {
+		t.Errorf("dump directory removed: %v", err)
+	}
+
+	// The root itself stays even once it is empty.
+	empty := filepath.Join(dir, "empty")
+	if err := os.MkdirAll(filepath.Join(empty, "sub"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	removeEmptyDirs(empty)
+	if entries, err := os.ReadDir(empty); err != nil || len(entries) != 0 {
+		t.Errorf("ReadDir(root) = %v, %v, want the root kept and empty", entries, err)
+	}
+}
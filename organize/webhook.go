@@ -0,0 +1,54 @@
+package organize
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+)
+
+var webhookClient = &http.Client{Timeout: 10 * time.Second}
+
+// sendWebhook POSTs the pass summary as JSON to url, retrying once on failure
+// after 1 to 3 seconds, jittered so instances sharing a receiver don't all
+// retry at once. It is meant to run in its own goroutine so it never blocks
+// organizing.
+func sendWebhook(url string, result *OrganizeResult) {
+	body, err := json.Marshal(result)
+	if err != nil {
+		log.Printf("failed to encode webhook payload: %v", err)
+		return
+	}
+
+	for attempt := 1; attempt <= 2; attempt++ {
+		err = postJSON(url, body)
+		if err == nil {
+			return
+		}
+		log.Printf("Webhook attempt %d failed: %v", attempt, err)
+		if attempt == 1 {
+			time.Sleep(webhookRetryDelay())
+		}
+	}
+}
+
+func postJSON(url string, body []byte) error {
+	resp, err := webhookClient.Post(url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("unexpected status: %s", resp.Status)
+	}
+	return nil
+}
+
+// webhookRetryDelay picks the wait before retrying a webhook from rng, so a
+// fixed seed retries after the same delay.
+func webhookRetryDelay() time.Duration {
+	return time.Second + time.Duration(rng.Float64()*float64(2*time.Second))
+}